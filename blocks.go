@@ -0,0 +1,116 @@
+package main
+
+import (
+	"fmt"
+	. "time"
+)
+
+// A window of time on some days of the week, e.g. weekday mornings, for restricting when a task
+// can or should be done
+type TaskBlock struct {
+	// Weekdays like "MO" or "monday", or every day if empty
+	Days     []string
+	Start    TimeWithoutDate
+	End      TimeWithoutDate
+	weekdays []Weekday
+}
+
+// The objective bonus for a task slot in one of its preferred blocks, as a fraction of its value,
+// if the objective section doesn't give one
+const DefaultPreferredBlockBonus = 0.25
+
+func (block *TaskBlock) parseDays() error {
+	block.weekdays = make([]Weekday, 0, len(block.Days))
+	for _, day := range block.Days {
+		weekday, err := parseWeekday(day)
+		if err != nil {
+			return err
+		}
+		block.weekdays = append(block.weekdays, weekday)
+	}
+	return nil
+}
+
+// Whether the slot from t for the duration falls within the block, in the local time of t
+func (block TaskBlock) contains(t Time, duration Duration) bool {
+	if len(block.weekdays) > 0 {
+		found := false
+		for _, weekday := range block.weekdays {
+			found = found || t.Weekday() == weekday
+		}
+		if !found {
+			return false
+		}
+	}
+	year, month, day := t.Date()
+	start := Date(year, month, day, block.Start.Hour(), block.Start.Minute(), 0, 0, t.Location())
+	end := Date(year, month, day, block.End.Hour(), block.End.Minute(), 0, 0, t.Location())
+	return !t.Before(start) && !t.Add(duration).After(end)
+}
+
+// Work out which of the TaskHours are in the task's allowed and preferred blocks, in the time zone
+// of the person whose slot it is. A task without allowed blocks is allowed in every slot.
+func (tp TaskParams) setBlockSlots(task *Task) error {
+	task.allowedSlots, task.preferredSlots = nil, nil
+	for i := range task.AllowedBlocks {
+		if err := task.AllowedBlocks[i].parseDays(); err != nil {
+			return fieldError(task.field(fmt.Sprintf("allowedBlocks[%d]", i)), err.Error())
+		}
+	}
+	for i := range task.PreferredBlocks {
+		if err := task.PreferredBlocks[i].parseDays(); err != nil {
+			return fieldError(task.field(fmt.Sprintf("preferredBlocks[%d]", i)), err.Error())
+		}
+	}
+	if len(task.AllowedBlocks) > 0 {
+		task.allowedSlots = tp.slotsInBlocks(task.AllowedBlocks)
+	}
+	if len(task.PreferredBlocks) > 0 {
+		task.preferredSlots = tp.slotsInBlocks(task.PreferredBlocks)
+	}
+	return nil
+}
+
+func (tp TaskParams) slotsInBlocks(blocks []TaskBlock) []bool {
+	inBlocks := make([]bool, len(tp.TaskHours))
+	for hour, t := range tp.TaskHours {
+		for _, block := range blocks {
+			inBlocks[hour] = inBlocks[hour] || block.contains(t.In(tp.slotLocation(hour)), tp.slotDuration())
+		}
+	}
+	return inBlocks
+}
+
+func (task Task) allowedSlot(hour int) bool {
+	return task.allowedSlots == nil || task.allowedSlots[hour]
+}
+
+// Whether any slot from the task's start is in its allowed blocks
+func (tp TaskParams) hasAllowedSlot(task Task) bool {
+	for hour := task.StartOnOrAfterHourIndex; hour >= 0 && hour < len(tp.TaskHours); hour++ {
+		if task.allowedSlot(hour) {
+			return true
+		}
+	}
+	return false
+}
+
+// Bound the columns for slots outside a task's allowed blocks to zero
+func (tp *TaskParams) addAllowedBlockBounds() {
+	for taskNum, task := range tp.Tasks {
+		first, end := tp.windowHours(taskNum)
+		for hour := first; hour < end; hour++ {
+			if !task.allowedSlot(hour) {
+				tp.lp.SetBounds(tp.col(hour, taskNum), 0, 0)
+			}
+		}
+	}
+}
+
+// The multiplier for the value of the task in the slot from being in one of its preferred blocks
+func (tp TaskParams) preferredBlockFactor(task Task, hour int) float64 {
+	if task.preferredSlots == nil || !task.preferredSlots[hour] {
+		return 1
+	}
+	return 1 + tp.Objective.PreferredBlockBonus
+}
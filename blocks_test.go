@@ -0,0 +1,75 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	. "time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestTaskBlocks(t *testing.T) {
+	in := `{
+		"timeZone": "America/New_York",
+		"detailed": true,
+		"weeklyTaskBlocks": [
+			[],
+			[{"start": "9:00", "end": "13:00"}],
+			[{"start": "9:00", "end": "13:00"}],
+			[],
+			[],
+			[],
+			[]
+		],
+		"tasks": [
+			{"title": "Writing", "estimatedHours": 2, "reward": 5, "allowedBlocks": [{"start": "9:00", "end": "11:00"}]},
+			{"title": "Email", "estimatedHours": 2, "reward": 10, "preferredBlocks": [{"start": "11:00", "end": "13:00"}]},
+			{"title": "Review", "estimatedHours": 1, "reward": 20, "allowedBlocks": [{"days": ["tuesday"], "start": "12:30", "end": "17:00"}]},
+			{"title": "Gardening", "estimatedHours": 1, "reward": 1, "allowedBlocks": [{"days": ["SA", "SU"], "start": "9:00", "end": "17:00"}]}
+		],
+		"startTaskSchedule": "2015-03-02T14:00:00Z",
+		"endTaskSchedule": "2015-03-04T14:00:00Z"
+	}`
+	ny, _ := LoadLocation("America/New_York")
+
+	Convey("Tasks are only scheduled in their allowed blocks and are drawn to their preferred ones", t, func() {
+		tp, err := computeSchedule([]byte(in))
+		So(err, ShouldBeNil)
+
+		events := make([]string, 0)
+		for _, event := range tp.TaskEvents {
+			events = append(events, event.Title+" "+event.Start.In(ny).Format("Mon 15:04")+"-"+event.End.In(ny).Format("15:04"))
+		}
+		// Without the blocks, Review and Email would be done first thing Monday. Review isn't done
+		// from 12:30 since it only fits a whole slot from 13:00, which is outside the work blocks.
+		So(events, ShouldResemble, []string{"Writing Mon 09:00-11:00", "Email Mon 11:00-13:00"})
+
+		So(tp.Unscheduled, ShouldResemble, []UnscheduledTask{
+			{Title: "Review", HoursRequested: 1, HoursScheduled: 0, Reason: NoAllowedSlots},
+			{Title: "Gardening", HoursRequested: 1, HoursScheduled: 0, Reason: NoAllowedSlots},
+		})
+	})
+
+	Convey("The allowed days and times are matched against whole slots", t, func() {
+		params := strings.Replace(in, `"start": "12:30"`, `"start": "12:00"`, 1)
+		tp, err := computeSchedule([]byte(params))
+		So(err, ShouldBeNil)
+		So(tp.TaskEvents[len(tp.TaskEvents)-1].Title, ShouldEqual, "Review")
+		So(tp.TaskEvents[len(tp.TaskEvents)-1].Start.In(ny).Format("Mon 15:04"), ShouldEqual, "Tue 12:00")
+	})
+
+	Convey("Without a bonus for preferred blocks Email is scheduled first", t, func() {
+		params := strings.Replace(in, `"detailed": true,`, `"objective": {"preferredBlockBonus": 0.001},`, 1)
+		tp, err := computeSchedule([]byte(params))
+		So(err, ShouldBeNil)
+		So(tp.TaskEvents[0].Title, ShouldEqual, "Email")
+		So(tp.TaskEvents[0].Start.In(ny).Format("Mon 15:04"), ShouldEqual, "Mon 09:00")
+	})
+
+	Convey("Invalid blocks are rejected", t, func() {
+		_, err := computeSchedule([]byte(strings.Replace(in, `"start": "11:00", "end": "13:00"`, `"start": "13:00", "end": "11:00"`, 1)))
+		So(err.Error(), ShouldEqual, "tasks[1].preferredBlocks[0].end must be after start")
+		_, err = computeSchedule([]byte(strings.Replace(in, `"tuesday"`, `"tuesdays"`, 1)))
+		So(err.Error(), ShouldEqual, "tasks[2].allowedBlocks[0].days[0] is not a weekday: tuesdays")
+	})
+}
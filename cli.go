@@ -0,0 +1,147 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+	"text/tabwriter"
+)
+
+const cliUsage = `Usage:
+  schedule                     Run the web service (listening on $PORT, default 8000)
+  schedule compute [flags]     Compute a schedule from a TaskParams JSON file
+
+Flags for compute:
+`
+
+// Run a command line subcommand, returning the exit code: 0 on success, 1 if the schedule couldn't
+// be computed and 2 for bad usage
+func runCLI(args []string, stdin io.Reader, stdout, stderr io.Writer) int {
+	flags := flag.NewFlagSet("compute", flag.ContinueOnError)
+	flags.SetOutput(stderr)
+	in := flags.String("in", "-", "TaskParams JSON file to read, or - for stdin")
+	format := flags.String("format", "json", "Output format: json, ics or table")
+	explain := flags.Bool("explain", false, "Write the generated linear program and objective as JSON instead of solving it")
+	flags.Usage = func() {
+		fmt.Fprint(stderr, cliUsage)
+		flags.PrintDefaults()
+	}
+
+	if len(args) == 0 || args[0] != "compute" {
+		flags.Usage()
+		return 2
+	}
+	if err := flags.Parse(args[1:]); err != nil {
+		return 2
+	}
+	// The input file can also be given as an argument, e.g. schedule compute params.json
+	if flags.NArg() > 1 {
+		flags.Usage()
+		return 2
+	} else if flags.NArg() == 1 {
+		*in = flags.Arg(0)
+	}
+	if *format != "json" && *format != "ics" && *format != "table" {
+		fmt.Fprintf(stderr, "Unknown format %q, expected json, ics or table\n", *format)
+		return 2
+	}
+
+	paramsJSON, err := readCLIInput(*in, stdin)
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+
+	var out []byte
+	if *explain {
+		var explanation *Explanation
+		if explanation, err = explainSchedule(paramsJSON); err == nil {
+			out, err = json.MarshalIndent(explanation, "", "  ")
+		}
+	} else {
+		var tp *TaskParams
+		tp, err = computeSchedule(paramsJSON)
+		switch {
+		case err != nil:
+		case *format == "json":
+			out, err = tp.taskScheduleJSON()
+		case *format == "ics":
+			out = tp.taskScheduleICS()
+		default:
+			out = tp.taskScheduleTable()
+		}
+		// The table says so itself, but the JSON and iCalendar schedules have nowhere to
+		if err == nil && tp.suboptimal && (*format == "json" || *format == "ics") {
+			fmt.Fprintln(stderr, "Not optimal: the solver stopped with the best schedule it had found")
+		}
+	}
+	if err != nil {
+		fmt.Fprintln(stderr, err)
+		if solveErr, ok := err.(*SolveError); ok {
+			fmt.Fprintln(stderr, "Solution type:", solveErr.Solution)
+		}
+		return 1
+	}
+
+	if _, err := stdout.Write(out); err != nil {
+		fmt.Fprintln(stderr, err)
+		return 1
+	}
+	return 0
+}
+
+func readCLIInput(in string, stdin io.Reader) ([]byte, error) {
+	if in == "-" {
+		return ioutil.ReadAll(stdin)
+	}
+	return ioutil.ReadFile(in)
+}
+
+// Render the task events as a table in the params time zone, followed by any unscheduled tasks
+func (tp *TaskParams) taskScheduleTable() []byte {
+	const dayLayout, timeLayout = "Mon Jan 2", "15:04"
+	var b bytes.Buffer
+	w := tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+
+	header := "DAY\tSTART\tEND\tHOURS\tTASK\tNOTE"
+	if len(tp.People) > 0 {
+		header += "\tASSIGNEE"
+	}
+	fmt.Fprintln(w, header)
+	for _, event := range tp.TaskEvents {
+		start, end := event.Start.In(tp.Location), event.End.In(tp.Location)
+		note := ""
+		if event.Finish {
+			note = "finish"
+		}
+		if event.Late {
+			note = strings.TrimSpace(note + " late")
+		}
+		fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%s", start.Format(dayLayout), start.Format(timeLayout),
+			end.Format(timeLayout), formatHours(end.Sub(start).Hours()), event.Title, note)
+		if len(tp.People) > 0 {
+			fmt.Fprintf(w, "\t%s", event.Assignee)
+		}
+		fmt.Fprintln(w)
+	}
+	w.Flush()
+
+	if len(tp.Unscheduled) > 0 {
+		fmt.Fprintln(&b, "\nNot fully scheduled:")
+		w = tabwriter.NewWriter(&b, 0, 4, 2, ' ', 0)
+		fmt.Fprintln(w, "TASK\tREQUESTED\tSCHEDULED\tREASON")
+		for _, task := range tp.Unscheduled {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\n", task.Title, formatHours(task.HoursRequested),
+				formatHours(task.HoursScheduled), task.Reason)
+		}
+		w.Flush()
+	}
+	if tp.suboptimal {
+		fmt.Fprintln(&b, "\nNot optimal: the solver stopped with the best schedule it had found")
+	}
+	return b.Bytes()
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestCLI(t *testing.T) {
+	in := `{
+		"timeZone": "America/New_York",
+		"detailed": true,
+		"weeklyTaskBlocks": [
+			[],
+			[{"start": "10:00", "end": "13:00"}],
+			[],
+			[],
+			[],
+			[],
+			[]
+		],
+		"tasks": [
+			{"title": "Newsletter", "estimatedHours": 2, "reward": 9},
+			{"title": "MPD", "estimatedHours": 3, "reward": 6}
+		],
+		"startTaskSchedule": "2015-03-02T14:00:00Z",
+		"endTaskSchedule": "2015-03-03T14:00:00Z"
+	}`
+
+	run := func(stdin string, args ...string) (int, string, string) {
+		var stdout, stderr bytes.Buffer
+		code := runCLI(args, strings.NewReader(stdin), &stdout, &stderr)
+		return code, stdout.String(), stderr.String()
+	}
+
+	Convey("compute writes the JSON schedule for params from stdin", t, func() {
+		code, stdout, stderr := run(in, "compute")
+		So(code, ShouldEqual, 0)
+		So(stderr, ShouldBeEmpty)
+
+		var resp ScheduleResponse
+		So(json.Unmarshal([]byte(stdout), &resp), ShouldBeNil)
+		So(len(resp.Events), ShouldEqual, 2)
+		So(len(resp.Unscheduled), ShouldEqual, 1)
+	})
+
+	Convey("compute reads the params from a file and writes a table", t, func() {
+		dir, err := ioutil.TempDir("", "schedule")
+		So(err, ShouldBeNil)
+		defer os.RemoveAll(dir)
+		path := filepath.Join(dir, "params.json")
+		So(ioutil.WriteFile(path, []byte(in), 0644), ShouldBeNil)
+
+		code, stdout, _ := run("", "compute", "-format", "table", path)
+		So(code, ShouldEqual, 0)
+		So(stdout, ShouldEqual, ""+
+			"DAY        START  END    HOURS  TASK        NOTE\n"+
+			"Mon Mar 2  10:00  12:00  2h     Newsletter  finish\n"+
+			"Mon Mar 2  12:00  13:00  1h     MPD         \n"+
+			"\nNot fully scheduled:\n"+
+			"TASK  REQUESTED  SCHEDULED  REASON\n"+
+			"MPD   3h         1h         horizonTooShort\n")
+	})
+
+	Convey("compute writes iCalendar", t, func() {
+		code, stdout, _ := run(in, "compute", "-format=ics", "-")
+		So(code, ShouldEqual, 0)
+		So(stdout, ShouldStartWith, "BEGIN:VCALENDAR")
+	})
+
+	Convey("compute exits with 1 and the error when the schedule can't be computed", t, func() {
+		infeasible := strings.Replace(in, `"reward": 9}`, `"reward": 9, "deadline": "2015-03-02T16:00:00Z"}`, 1)
+		code, stdout, stderr := run(infeasible, "compute")
+		So(code, ShouldEqual, 1)
+		So(stdout, ShouldBeEmpty)
+		So(stderr, ShouldEqual, "Could not solve linear program\nSolution type: INFEASIBLE\n")
+
+		code, _, stderr = run("", "compute", "missing.json")
+		So(code, ShouldEqual, 1)
+		So(stderr, ShouldContainSubstring, "missing.json")
+	})
+
+	Convey("Bad usage exits with 2", t, func() {
+		code, _, stderr := run(in)
+		So(code, ShouldEqual, 2)
+		So(stderr, ShouldStartWith, "Usage:")
+
+		code, _, _ = run(in, "serve")
+		So(code, ShouldEqual, 2)
+
+		code, _, stderr = run(in, "compute", "-format", "csv")
+		So(code, ShouldEqual, 2)
+		So(stderr, ShouldContainSubstring, `Unknown format "csv"`)
+	})
+}
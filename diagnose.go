@@ -0,0 +1,150 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+	. "time"
+)
+
+// Returned when the linear program couldn't be solved optimally. In diagnose mode for an
+// infeasible program, Conflict is the minimal set of tasks whose deadlines can't all be met.
+type SolveError struct {
+	Solution SolutionType
+	Conflict *DeadlineConflict
+}
+
+type DeadlineConflict struct {
+	Tasks          []string `json:"tasks"`
+	HoursNeeded    float64  `json:"hoursNeeded"`
+	SlotsAvailable int      `json:"slotsAvailable"`
+	Deadline       Time     `json:"deadline"`
+	Message        string   `json:"message"`
+}
+
+func (e *SolveError) Error() string {
+	msg := "Could not solve linear program"
+	if e.Conflict != nil {
+		msg += ": " + e.Conflict.Message
+	}
+	return msg
+}
+
+func (tp *TaskParams) solveErr(ret SolutionType) error {
+	solveErr := &SolveError{Solution: ret}
+	if ret == INFEASIBLE && tp.Diagnose {
+		conflict, err := tp.diagnoseDeadlineConflict()
+		if err != nil {
+			return err
+		}
+		if stopRet, stop := tp.solveStopped(); stop {
+			// A job that timed out or was canceled while diagnosing has no conflict to report
+			return &SolveError{Solution: stopRet}
+		}
+		solveErr.Conflict = conflict
+	}
+	return solveErr
+}
+
+// Find a minimal set of deadlines that can't all be met by dropping the deadlines one at a time and
+// keeping them dropped whenever the remaining ones are still infeasible. Each remaining deadline is
+// then necessary for the conflict. Returns nil if the program is infeasible even without deadlines.
+func (tp *TaskParams) diagnoseDeadlineConflict() (*DeadlineConflict, error) {
+	defer func() {
+		for i := range tp.Tasks {
+			tp.Tasks[i].relaxDeadline = false
+		}
+	}()
+
+	conflictNums := make([]int, 0)
+	for taskNum, task := range tp.Tasks {
+		if task.hasDeadline(len(tp.TaskHours)) {
+			conflictNums = append(conflictNums, taskNum)
+		}
+	}
+
+	for _, taskNum := range conflictNums {
+		tp.Tasks[taskNum].relaxDeadline = true
+	}
+	feasible, err := tp.deadlinesFeasible()
+	if err != nil || !feasible {
+		// Still infeasible without the deadlines, so they aren't what conflicts
+		return nil, err
+	}
+
+	for i := 0; i < len(conflictNums); {
+		// Enforce all the deadlines still in the conflict except this one
+		for j, taskNum := range conflictNums {
+			tp.Tasks[taskNum].relaxDeadline = j == i
+		}
+		feasible, err := tp.deadlinesFeasible()
+		if err != nil {
+			return nil, err
+		}
+		if feasible {
+			i++
+		} else {
+			conflictNums = append(conflictNums[:i], conflictNums[i+1:]...)
+		}
+	}
+
+	return tp.deadlineConflict(conflictNums), nil
+}
+
+func (tp *TaskParams) deadlinesFeasible() (bool, error) {
+	ret, _, err := tp.solve()
+	return ret != INFEASIBLE, err
+}
+
+func (tp TaskParams) deadlineConflict(taskNums []int) *DeadlineConflict {
+	sort.Slice(taskNums, func(i, j int) bool {
+		return tp.Tasks[taskNums[i]].DeadlineHourIndex < tp.Tasks[taskNums[j]].DeadlineHourIndex
+	})
+
+	conflict := &DeadlineConflict{Tasks: make([]string, 0)}
+	descriptions := make([]string, 0)
+	firstStart := len(tp.TaskHours)
+	lastDeadline := -1
+	slotHours := tp.slotDuration().Hours()
+	for _, taskNum := range taskNums {
+		task := tp.Tasks[taskNum]
+		hours := float64(task.estimatedSlots) * slotHours
+		conflict.Tasks = append(conflict.Tasks, task.Title)
+		conflict.HoursNeeded += hours
+		descriptions = append(descriptions, task.Title+" ("+formatHours(hours)+")")
+		if task.StartOnOrAfterHourIndex >= 0 && task.StartOnOrAfterHourIndex < firstStart {
+			firstStart = task.StartOnOrAfterHourIndex
+		}
+		if task.DeadlineHourIndex > lastDeadline {
+			lastDeadline = task.DeadlineHourIndex
+			conflict.Deadline = task.Deadline
+		}
+	}
+	if lastDeadline >= firstStart {
+		conflict.SlotsAvailable = lastDeadline - firstStart + 1
+	}
+
+	who := strings.Join(descriptions, " and ")
+	if len(descriptions) > 2 {
+		who = strings.Join(descriptions[:len(descriptions)-1], ", ") + " and " + descriptions[len(descriptions)-1]
+	}
+	verb := "need"
+	if len(descriptions) == 1 {
+		verb = "needs"
+	}
+	deadline := conflict.Deadline.In(tp.Location).Format("Jan 2 15:04")
+	if conflict.HoursNeeded > float64(conflict.SlotsAvailable)*slotHours {
+		conflict.Message = who + " " + verb + " " + formatHours(conflict.HoursNeeded) + " before " + deadline +
+			" but only " + strconv.Itoa(conflict.SlotsAvailable) + " slots exist"
+	} else if len(descriptions) == 1 {
+		conflict.Message = who + " can't be finished by its deadline of " + deadline
+	} else {
+		conflict.Message = who + " can't all be finished by their deadlines (the last is " + deadline + ")"
+	}
+	conflict.Deadline = conflict.Deadline.In(UTC)
+	return conflict
+}
+
+func formatHours(hours float64) string {
+	return strconv.FormatFloat(hours, 'f', -1, 64) + "h"
+}
@@ -0,0 +1,103 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	. "time"
+)
+
+// The model generated for a request, without solving it, to help debug why a task was scheduled
+// where it was
+type Explanation struct {
+	LP        string `json:"lp"`
+	TaskHours []Time `json:"taskHours"`
+	// The person whose slot each of the TaskHours is, with people
+	SlotPeople []string          `json:"slotPeople,omitempty"`
+	Tasks      []TaskExplanation `json:"tasks"`
+}
+
+type TaskExplanation struct {
+	Title                   string `json:"title"`
+	DeadlineHourIndex       int    `json:"deadlineHourIndex"`
+	StartOnOrAfterHourIndex int    `json:"startOnOrAfterHourIndex"`
+	// The objective coefficient of the task for each of the TaskHours
+	ObjectiveCoefficients []float64 `json:"objectiveCoefficients"`
+	// The objective coefficient for each late slot with a soft deadline
+	LateCoefficient float64 `json:"lateCoefficient,omitempty"`
+}
+
+func explainHandler(w http.ResponseWriter, r *http.Request) {
+	// Allow CORS requests
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "content-type")
+
+	if r.Method != "POST" {
+		w.Write([]byte("OK"))
+		return
+	}
+
+	body, err := requestParamsJSON(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var resp interface{}
+	status := http.StatusOK
+	explanation, err := explainSchedule(body)
+	if err != nil {
+		resp = errResponse(err)
+		status = errStatus(err)
+	} else {
+		resp = explanation
+	}
+	respJSON, err := json.MarshalIndent(resp, "", "  ")
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.WriteHeader(status)
+	w.Write(respJSON)
+}
+
+// Build the linear program for the params as calcSchedule would, but explain it rather than solve it
+func explainSchedule(paramsJSON []byte) (*Explanation, error) {
+	var tp TaskParams
+	if err := parseTaskParams(paramsJSON, &tp); err != nil {
+		return nil, err
+	}
+	if err := tp.setupLP(); err != nil {
+		return nil, err
+	}
+
+	explanation := &Explanation{
+		LP:        tp.lp.WriteToString(),
+		TaskHours: make([]Time, len(tp.TaskHours)),
+		Tasks:     make([]TaskExplanation, len(tp.Tasks)),
+	}
+	for hour, t := range tp.TaskHours {
+		explanation.TaskHours[hour] = t.UTC()
+		if len(tp.People) > 0 {
+			explanation.SlotPeople = append(explanation.SlotPeople, tp.slotPersonName(hour))
+		}
+	}
+	for taskNum, task := range tp.Tasks {
+		// Hours outside the task's window have no column, and so a coefficient of zero
+		coefficients := make([]float64, len(tp.TaskHours))
+		for hour := range tp.TaskHours {
+			if col := tp.col(hour, taskNum); col >= 0 {
+				coefficients[hour] = tp.objective[col]
+			}
+		}
+		explanation.Tasks[taskNum] = TaskExplanation{
+			Title:                   task.Title,
+			DeadlineHourIndex:       task.DeadlineHourIndex,
+			StartOnOrAfterHourIndex: task.StartOnOrAfterHourIndex,
+			ObjectiveCoefficients:   coefficients,
+		}
+		if task.softDeadline {
+			explanation.Tasks[taskNum].LateCoefficient = tp.objective[task.lateCol]
+		}
+	}
+	return explanation, nil
+}
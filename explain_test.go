@@ -0,0 +1,77 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	. "time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestExplain(t *testing.T) {
+	in := `{
+		"timeZone": "America/New_York",
+		"weeklyTaskBlocks": [
+			[],
+			[{"start": "10:00", "end": "12:00"}],
+			[{"start": "9:00", "end": "10:00"}],
+			[],
+			[],
+			[],
+			[]
+		],
+		"tasks": [
+			{"title": "Newsletter", "estimatedHours": 2, "reward": 10, "deadline": "2015-03-03T15:00:00Z"},
+			{"title": "MPD", "estimatedHours": 1, "reward": 5, "startOnOrAfter": "2015-03-02T16:00:00Z",
+				"deadline": "2015-03-02T17:00:00Z", "deadlineType": "soft", "latePenaltyPerHour": 2}
+		],
+		"startTaskSchedule": "2015-03-02T14:00:00Z",
+		"endTaskSchedule": "2015-03-04T14:00:00Z"
+	}`
+
+	Convey("The explanation has the LP, slots, hour indices and objective coefficients", t, func() {
+		explanation, err := explainSchedule([]byte(in))
+		So(err, ShouldBeNil)
+
+		So(explanation.TaskHours, ShouldResemble, []Time{
+			Date(2015, 3, 2, 15, 0, 0, 0, UTC), Date(2015, 3, 2, 16, 0, 0, 0, UTC), Date(2015, 3, 3, 14, 0, 0, 0, UTC),
+		})
+		So(explanation.LP, ShouldStartWith, "/* Objective function */\nmax:")
+		So(explanation.LP, ShouldContainSubstring, "h2_t0")
+		So(explanation.LP, ShouldContainSubstring, "late_t1")
+
+		newsletter, mpd := explanation.Tasks[0], explanation.Tasks[1]
+		So(newsletter.Title, ShouldEqual, "Newsletter")
+		So(newsletter.DeadlineHourIndex, ShouldEqual, 2)
+		So(newsletter.StartOnOrAfterHourIndex, ShouldEqual, 0)
+		So(len(newsletter.ObjectiveCoefficients), ShouldEqual, 3)
+		So(newsletter.ObjectiveCoefficients[1], ShouldAlmostEqual, 0.99*newsletter.ObjectiveCoefficients[0])
+		So(newsletter.LateCoefficient, ShouldEqual, 0)
+
+		So(mpd.DeadlineHourIndex, ShouldEqual, 1)
+		So(mpd.StartOnOrAfterHourIndex, ShouldEqual, 1)
+		So(mpd.LateCoefficient, ShouldEqual, -2)
+	})
+
+	Convey("The /explain endpoint returns the explanation as JSON", t, func() {
+		r := httptest.NewRequest("POST", "/explain", strings.NewReader(in))
+		w := httptest.NewRecorder()
+		explainHandler(w, r)
+		So(w.Code, ShouldEqual, http.StatusOK)
+
+		var explanation Explanation
+		So(json.Unmarshal(w.Body.Bytes(), &explanation), ShouldBeNil)
+		So(len(explanation.Tasks), ShouldEqual, 2)
+		So(len(explanation.TaskHours), ShouldEqual, 3)
+	})
+
+	Convey("The compute subcommand explains with -explain", t, func() {
+		var stdout, stderr strings.Builder
+		code := runCLI([]string{"compute", "-explain"}, strings.NewReader(in), &stdout, &stderr)
+		So(code, ShouldEqual, 0)
+		So(stdout.String(), ShouldContainSubstring, `"objectiveCoefficients"`)
+	})
+}
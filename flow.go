@@ -0,0 +1,263 @@
+package main
+
+import (
+	"container/heap"
+	"math"
+)
+
+// Names for the engine option of a request
+const (
+	LPEngine   = "lp"
+	FlowEngine = "flow"
+)
+
+// Without chunks, per day or week limits or dependencies, scheduling is a transportation problem:
+// each task supplies its estimated slots, each slot takes at most one of them, and assigning a
+// slot of a task earns that column's objective coefficient. The flow engine solves it as a min
+// cost flow from a source through the tasks and slots to a sink, which needs no LP at all and
+// only has edges for the slots each task can be in.
+//
+// Hard deadlines need all of a task's slots by the deadline. Those edges get a bonus bigger than
+// the whole objective, so every solution that meets the deadlines beats every one that doesn't,
+// and if the best flow still misses one, no schedule can meet them all. A soft deadline's late
+// penalty is turned into a bonus for each slot by the deadline, since the late slots are the
+// estimate less those.
+func (tp *TaskParams) solveFlow() (SolutionType, []float64, error) {
+	tp.setColumns()
+	objective := tp.objectiveRow()
+	numHours := len(tp.TaskHours)
+
+	maxValue := 0.0
+	for _, coefficient := range objective {
+		maxValue = math.Max(maxValue, math.Abs(coefficient))
+	}
+	// More than any two schedules can differ by without it, as no slot is worth more than twice
+	// maxValue with a late penalty
+	deadlineBonus := 1 + 4*float64(numHours)*maxValue
+
+	// Locked slots are assigned up front and left out of the flow
+	vars := make([]float64, tp.numCols)
+	lockedHours := make([]bool, numHours)
+	remaining := make([]int, len(tp.Tasks))
+	for taskNum, task := range tp.Tasks {
+		remaining[taskNum] = task.estimatedSlots
+		for _, hour := range task.lockedSlots {
+			if hour < task.StartOnOrAfterHourIndex || (tp.hardDeadline(task) && hour > task.DeadlineHourIndex) {
+				return INFEASIBLE, nil, nil
+			}
+			vars[tp.col(hour, taskNum)] = 1
+			lockedHours[hour] = true
+			remaining[taskNum]--
+		}
+	}
+
+	source, sink := 0, len(tp.Tasks)+numHours+1
+	graph := newFlowGraph(sink + 1)
+	for taskNum, task := range tp.Tasks {
+		graph.addEdge(source, 1+taskNum, remaining[taskNum], 0)
+		if task.StartOnOrAfterHourIndex < 0 {
+			continue
+		}
+		lastHour := numHours - 1
+		if tp.hardDeadline(task) {
+			lastHour = task.DeadlineHourIndex
+		}
+		for hour := task.StartOnOrAfterHourIndex; hour <= lastHour; hour++ {
+			if lockedHours[hour] || !task.allowedSlot(hour) {
+				continue
+			}
+			value := objective[tp.col(hour, taskNum)]
+			if tp.hardDeadline(task) {
+				value += deadlineBonus
+			}
+			if task.softDeadline && task.DeadlineHourIndex < numHours && hour <= task.DeadlineHourIndex {
+				value -= objective[task.lateCol]
+			}
+			graph.addEdge(1+taskNum, 1+len(tp.Tasks)+hour, 1, -value)
+		}
+	}
+	for hour := 0; hour < numHours; hour++ {
+		graph.addEdge(1+len(tp.Tasks)+hour, sink, 1, 0)
+	}
+	stop := func() bool {
+		_, stop := tp.solveStopped()
+		return stop
+	}
+	if !graph.minCostFlow(source, sink, stop) {
+		// A job that timed out or was canceled
+		ret, _ := tp.solveStopped()
+		return ret, nil, nil
+	}
+
+	for taskNum, task := range tp.Tasks {
+		for _, edge := range graph.edges[1+taskNum] {
+			if edge.to > len(tp.Tasks) && edge.to != sink && edge.cap == 0 {
+				vars[tp.col(edge.to-1-len(tp.Tasks), taskNum)] = 1
+			}
+		}
+		slots, slotsByDeadline := 0, 0
+		first, end := tp.windowHours(taskNum)
+		for hour := first; hour < end; hour++ {
+			if vars[tp.col(hour, taskNum)] == 1 {
+				slots++
+				if hour <= task.DeadlineHourIndex {
+					slotsByDeadline++
+				}
+			}
+		}
+		if tp.hardDeadline(task) && slots < task.estimatedSlots {
+			return INFEASIBLE, nil, nil
+		}
+		if task.softDeadline && task.DeadlineHourIndex < numHours {
+			vars[task.lateCol] = float64(task.estimatedSlots - slotsByDeadline)
+		}
+	}
+	return OPTIMAL, vars, nil
+}
+
+// The constraints the flow engine can't express, checked once the tasks' slots are known
+func (tp TaskParams) checkFlowEngine() error {
+	const message = "can't be scheduled by the flow engine"
+	for _, task := range tp.Tasks {
+		switch {
+		case task.minChunkSlots > 1:
+			return fieldError(task.field("minChunkHours"), message)
+		case task.maxChunkSlots > 0:
+			return fieldError(task.field("maxChunkHours"), message)
+		case task.maxSlotsPerDay > 0:
+			return fieldError(task.field("maxHoursPerDay"), message)
+		case task.maxSlotsPerWeek > 0:
+			return fieldError(task.field("maxHoursPerWeek"), message)
+		case len(task.dependsOn) > 0:
+			return fieldError(task.field("dependsOn"), message)
+		}
+	}
+	return nil
+}
+
+type flowEdge struct {
+	to, rev int
+	cap     int
+	cost    float64
+}
+
+type flowGraph struct {
+	edges [][]flowEdge
+}
+
+func newFlowGraph(numNodes int) *flowGraph {
+	return &flowGraph{edges: make([][]flowEdge, numNodes)}
+}
+
+func (g *flowGraph) addEdge(from, to, cap int, cost float64) {
+	g.edges[from] = append(g.edges[from], flowEdge{to, len(g.edges[to]), cap, cost})
+	g.edges[to] = append(g.edges[to], flowEdge{from, len(g.edges[from]) - 1, 0, -cost})
+}
+
+// Send flow from the source to the sink one unit at a time along the cheapest path, for as long as
+// that lowers the cost. Dijkstra's algorithm finds the paths, using node potentials to keep the
+// edge costs non-negative. Returns false if stop returned true before the flow was done.
+func (g *flowGraph) minCostFlow(source, sink int, stop func() bool) bool {
+	numNodes := len(g.edges)
+	potential := g.initialPotentials(source)
+	dist := make([]float64, numNodes)
+	prevNode := make([]int, numNodes)
+	prevEdge := make([]int, numNodes)
+
+	for {
+		if stop() {
+			return false
+		}
+		for node := range dist {
+			dist[node] = math.Inf(1)
+		}
+		dist[source] = 0
+		queue := &flowQueue{{source, 0}}
+		for queue.Len() > 0 {
+			item := heap.Pop(queue).(flowQueueItem)
+			if item.dist > dist[item.node] {
+				continue
+			}
+			if item.node == sink {
+				break
+			}
+			for i, edge := range g.edges[item.node] {
+				if edge.cap == 0 {
+					continue
+				}
+				// Rounding can leave reduced costs a little below zero
+				reduced := math.Max(edge.cost+potential[item.node]-potential[edge.to], 0)
+				if d := item.dist + reduced; d < dist[edge.to] {
+					dist[edge.to] = d
+					prevNode[edge.to], prevEdge[edge.to] = item.node, i
+					heap.Push(queue, flowQueueItem{edge.to, d})
+				}
+			}
+		}
+		if math.IsInf(dist[sink], 1) {
+			return true
+		}
+		// Nodes not reached before the sink are at least as far as it, which keeps the reduced
+		// costs non-negative
+		for node := range potential {
+			potential[node] += math.Min(dist[node], dist[sink])
+		}
+		// The potentials are now the cost of the cheapest paths from the source
+		if potential[sink]-potential[source] >= 0 {
+			return true
+		}
+		for node := sink; node != source; node = prevNode[node] {
+			edge := &g.edges[prevNode[node]][prevEdge[node]]
+			edge.cap--
+			g.edges[node][edge.rev].cap++
+		}
+	}
+}
+
+// The cost of the cheapest path from the source to each node, by Bellman-Ford, as the edges out of
+// the tasks can have negative costs
+func (g *flowGraph) initialPotentials(source int) []float64 {
+	potential := make([]float64, len(g.edges))
+	for node := range potential {
+		potential[node] = math.Inf(1)
+	}
+	potential[source] = 0
+	for changed := true; changed; {
+		changed = false
+		for node, edges := range g.edges {
+			if math.IsInf(potential[node], 1) {
+				continue
+			}
+			for _, edge := range edges {
+				if edge.cap > 0 && potential[node]+edge.cost < potential[edge.to] {
+					potential[edge.to] = potential[node] + edge.cost
+					changed = true
+				}
+			}
+		}
+	}
+	for node := range potential {
+		if math.IsInf(potential[node], 1) {
+			potential[node] = 0
+		}
+	}
+	return potential
+}
+
+type flowQueueItem struct {
+	node int
+	dist float64
+}
+
+type flowQueue []flowQueueItem
+
+func (q flowQueue) Len() int            { return len(q) }
+func (q flowQueue) Less(i, j int) bool  { return q[i].dist < q[j].dist }
+func (q flowQueue) Swap(i, j int)       { q[i], q[j] = q[j], q[i] }
+func (q *flowQueue) Push(x interface{}) { *q = append(*q, x.(flowQueueItem)) }
+func (q *flowQueue) Pop() interface{} {
+	old := *q
+	item := old[len(old)-1]
+	*q = old[:len(old)-1]
+	return item
+}
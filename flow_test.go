@@ -0,0 +1,117 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"math/rand"
+	"strings"
+	"testing"
+	. "time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+// Random requests without the constraints the flow engine can't express
+func randomFlowParams(r *rand.Rand) map[string]interface{} {
+	start := Date(2015, 3, 2, 14, 0, 0, 0, UTC)
+	hoursLater := func(hours int) string {
+		return start.Add(Duration(hours) * Hour).Format(RFC3339)
+	}
+	blocks := make([][]map[string]string, 7)
+	for day := range blocks {
+		blocks[day] = []map[string]string{}
+		if day > 0 && day < 6 {
+			startHour := 8 + r.Intn(3)
+			blocks[day] = append(blocks[day], map[string]string{
+				"start": fmt.Sprintf("%d:00", startHour), "end": fmt.Sprintf("%d:00", startHour+2+r.Intn(3)),
+			})
+		}
+	}
+
+	tasks := make([]map[string]interface{}, 2+r.Intn(5))
+	for i := range tasks {
+		task := map[string]interface{}{
+			"title":          fmt.Sprintf("Task %d", i),
+			"estimatedHours": 1 + r.Intn(4),
+			"reward":         1 + r.Intn(20),
+		}
+		switch r.Intn(4) {
+		case 0:
+			task["deadline"] = hoursLater(24 + r.Intn(72))
+		case 1:
+			task["deadline"] = hoursLater(r.Intn(72))
+			task["deadlineType"] = "soft"
+			task["latePenaltyPerHour"] = r.Intn(10)
+		}
+		if r.Intn(4) == 0 {
+			task["startOnOrAfter"] = hoursLater(r.Intn(48))
+		}
+		switch r.Intn(5) {
+		case 0:
+			task["allowedBlocks"] = []map[string]string{{"start": "9:00", "end": "11:00"}}
+		case 1:
+			task["preferredBlocks"] = []map[string]interface{}{
+				{"days": []string{[]string{"TU", "TH"}[r.Intn(2)]}, "start": "10:00", "end": "12:00"},
+			}
+		}
+		tasks[i] = task
+	}
+
+	params := map[string]interface{}{
+		"timeZone":          "America/New_York",
+		"weeklyTaskBlocks":  blocks,
+		"tasks":             tasks,
+		"startTaskSchedule": hoursLater(0),
+		"endTaskSchedule":   hoursLater(24 * (2 + r.Intn(5))),
+	}
+	if r.Intn(3) == 0 {
+		params["slotMinutes"] = 30
+	}
+	return params
+}
+
+func TestFlowEngine(t *testing.T) {
+	Convey("The flow engine gives the same schedules as the LP on random requests", t, func() {
+		r := rand.New(rand.NewSource(1))
+		feasible := 0
+		for i := 0; i < 200; i++ {
+			params := randomFlowParams(r)
+			paramsJSON, err := json.Marshal(params)
+			So(err, ShouldBeNil)
+			lpTP, lpErr := computeSchedule(paramsJSON)
+			params["engine"] = "flow"
+			paramsJSON, err = json.Marshal(params)
+			So(err, ShouldBeNil)
+			flowTP, flowErr := computeSchedule(paramsJSON)
+
+			if lpErr != nil {
+				So(flowErr, ShouldNotBeNil)
+				So(flowErr.Error(), ShouldEqual, lpErr.Error())
+				continue
+			}
+			So(flowErr, ShouldBeNil)
+			feasible++
+			lpOut, _ := lpTP.taskScheduleJSON()
+			flowOut, _ := flowTP.taskScheduleJSON()
+			So(string(flowOut), ShouldEqual, string(lpOut))
+			So(flowTP.ObjectiveValue, ShouldAlmostEqual, lpTP.ObjectiveValue, 0.000001)
+		}
+		So(feasible, ShouldBeGreaterThan, 100)
+	})
+
+	Convey("It rejects constraints it can't express", t, func() {
+		in := `{
+			"timeZone": "America/New_York",
+			"engine": "flow",
+			"weeklyTaskBlocks": [[], [{"start": "9:00", "end": "12:00"}], [], [], [], [], []],
+			"tasks": [{"title": "Writing", "estimatedHours": 2, "reward": 5, "minChunkHours": 2}],
+			"startTaskSchedule": "2015-03-02T14:00:00Z",
+			"endTaskSchedule": "2015-03-03T14:00:00Z"
+		}`
+		_, err := computeSchedule([]byte(in))
+		So(err.Error(), ShouldEqual, "tasks[0].minChunkHours can't be scheduled by the flow engine")
+
+		_, err = computeSchedule([]byte(strings.Replace(in, `"flow"`, `"simplex"`, 1)))
+		So(err.Error(), ShouldEqual, `engine must be "lp" or "flow"`)
+	})
+}
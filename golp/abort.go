@@ -0,0 +1,19 @@
+package golp
+
+/*
+#include <stdint.h>
+*/
+import "C"
+
+import "runtime/cgo"
+
+// Called by lp_solve through abort_callback in lp.go with the handle of the function passed to
+// SetAbortFunc. The C code in this file can only have declarations because of the export.
+//
+//export golpAbort
+func golpAbort(handle C.uintptr_t) C.int {
+	if cgo.Handle(handle).Value().(func() bool)() {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,291 @@
+// NOTE: This code starts column index at 0, converts to starting at 1 for lpsolve library
+
+package golp
+
+/*
+#cgo CFLAGS: -I./lib/lp_solve
+#cgo LDFLAGS: -L./lib/lp_solve/ -llpsolve55 -Wl,-rpath=./lib/lp_solve
+#include <stdint.h>
+#include <stdlib.h>
+#include "lp_lib.h"
+#include "stringbuilder.h"
+
+int write_lp_to_str_callback(void* userhandle, char* buf) {
+	sb_append_str((stringbuilder*) userhandle, buf);
+	return 0;
+}
+
+char* write_lp_to_str(lprec *lp) {
+	stringbuilder* sb = sb_new();
+	write_lpex(lp, sb, write_lp_to_str_callback);
+	char* str = sb_cstring(sb);
+	sb_destroy(sb, 0);
+	return str;
+}
+
+// Exported from abort.go
+extern int golpAbort(uintptr_t handle);
+
+int __WINAPI abort_callback(lprec *lp, void *userhandle) {
+	return golpAbort((uintptr_t) userhandle);
+}
+
+void set_abort_handle(lprec *lp, uintptr_t handle) {
+	put_abortfunc(lp, handle ? abort_callback : NULL, (void*) handle);
+}
+*/
+import "C"
+
+import (
+	"runtime"
+	"runtime/cgo"
+	"strconv"
+	"unsafe"
+)
+
+type LP struct {
+	ptr         *C.lprec
+	abortHandle cgo.Handle
+}
+
+func NewLP(rows, cols int) *LP {
+	l := new(LP)
+	l.ptr = C.make_lp(C.int(rows), C.int(cols))
+	runtime.SetFinalizer(l, deleteLP)
+	l.SetAddRowMode(true)
+	return l
+}
+
+const ( // iota is reset to 0
+	NEUTRAL  = iota // NEUTRAL == 0
+	CRITICAL        // CRITICAL == 1
+	SEVERE
+	IMPORTANT
+	NORMAL
+	DETAILED
+	FULL
+)
+
+func (l *LP) SetVerboseLevel(level int) {
+	C.set_verbose(l.ptr, C.int(level))
+}
+
+func deleteLP(l *LP) {
+	C.delete_lp(l.ptr)
+	if l.abortHandle != 0 {
+		l.abortHandle.Delete()
+	}
+}
+
+func (l *LP) SetColName(col int, name string) {
+	cstrName := C.CString(name)
+	C.set_col_name(l.ptr, C.int(col+1), cstrName)
+	C.free(unsafe.Pointer(cstrName))
+}
+
+func (l *LP) GetColName(col int) string {
+	return C.GoString(C.get_col_name(l.ptr, C.int(col+1)))
+}
+
+// SetInt sets whether the column must take an integer value in the solution
+func (l *LP) SetInt(col int, mustBeInt bool) {
+	C.set_int(l.ptr, C.int(col+1), boolToUChar(mustBeInt))
+}
+
+func (l *LP) IsInt(col int) bool {
+	return C.is_int(l.ptr, C.int(col+1)) != 0
+}
+
+// SetBinary sets whether the column must be either 0 or 1 in the solution.
+// This makes it integer and sets its bounds to [0, 1].
+func (l *LP) SetBinary(col int, mustBeBinary bool) {
+	C.set_binary(l.ptr, C.int(col+1), boolToUChar(mustBeBinary))
+}
+
+func (l *LP) IsBinary(col int) bool {
+	return C.is_binary(l.ptr, C.int(col+1)) != 0
+}
+
+// SetBounds sets the lower and upper bounds of the column. The default bounds are 0 and infinity.
+func (l *LP) SetBounds(col int, lower, upper float64) {
+	C.set_bounds(l.ptr, C.int(col+1), C.double(lower), C.double(upper))
+}
+
+func (l *LP) GetBounds(col int) (lower, upper float64) {
+	return float64(C.get_lowbo(l.ptr, C.int(col+1))), float64(C.get_upbo(l.ptr, C.int(col+1)))
+}
+
+// SetUnbounded sets the column's bounds to negative and positive infinity
+func (l *LP) SetUnbounded(col int) {
+	C.set_unbounded(l.ptr, C.int(col+1))
+}
+
+// SetBreakAtFirst sets whether the branch and bound algorithm stops at the first integer solution
+// found rather than continuing to search for the optimal one
+func (l *LP) SetBreakAtFirst(breakAtFirst bool) {
+	C.set_break_at_first(l.ptr, boolToUChar(breakAtFirst))
+}
+
+func (l *LP) IsBreakAtFirst() bool {
+	return C.is_break_at_first(l.ptr) != 0
+}
+
+// SetTimeout sets the number of seconds Solve can take before it stops with TIMEOUT, or SUBOPTIMAL if
+// it has found an integer solution by then. Zero means no timeout, which is the default.
+func (l *LP) SetTimeout(seconds int) {
+	C.set_timeout(l.ptr, C.long(seconds))
+}
+
+func (l *LP) GetTimeout() int {
+	return int(C.get_timeout(l.ptr))
+}
+
+// SetAbortFunc sets a function Solve calls every so often, and which stops it with USERABORT by
+// returning true. It's called on the goroutine calling Solve. A nil function removes it.
+func (l *LP) SetAbortFunc(abort func() bool) {
+	if l.abortHandle != 0 {
+		l.abortHandle.Delete()
+		l.abortHandle = 0
+	}
+	if abort != nil {
+		l.abortHandle = cgo.NewHandle(abort)
+	}
+	C.set_abort_handle(l.ptr, C.uintptr_t(l.abortHandle))
+}
+
+func (l *LP) SetAddRowMode(addRowMode bool) {
+	C.set_add_rowmode(l.ptr, boolToUChar(addRowMode))
+}
+
+func boolToUChar(b bool) C.uchar {
+	if b {
+		return C.uchar(1)
+	}
+	return C.uchar(0)
+}
+
+type ConstraintType int
+
+const ( // iota is reset to 0
+	_  = iota // don't use 0
+	LE        // LE == 1
+	GE        // GE == 2
+	EQ        // EQ == 3
+)
+
+func (l *LP) AddConstraint(row []float64, ct ConstraintType, rightHand float64) error {
+	cRow := make([]C.double, len(row)+1)
+	cRow[0] = 0.0
+	for i := 0; i < len(row); i++ {
+		cRow[i+1] = C.double(row[i])
+	}
+	C.add_constraint(l.ptr, &cRow[0], C.int(ct), C.double(rightHand))
+	return nil
+}
+
+type Entry struct {
+	Col int
+	Val float64
+}
+
+func (l *LP) AddConstraintSparse(row []Entry, ct ConstraintType, rightHand float64) error {
+	cRow := make([]C.double, len(row))
+	cColNums := make([]C.int, len(row))
+	for i, entry := range row {
+		cRow[i] = C.double(entry.Val)
+		cColNums[i] = C.int(entry.Col + 1)
+	}
+	C.add_constraintex(l.ptr, C.int(len(row)), &cRow[0], &cColNums[0], C.int(ct), C.double(rightHand))
+	return nil
+}
+
+func (l *LP) SetObjFn(row []float64, maximize bool) {
+	l.SetAddRowMode(false)
+
+	cRow := make([]C.double, len(row)+1)
+	cRow[0] = 0.0
+	for i := 0; i < len(row); i++ {
+		cRow[i+1] = C.double(row[i])
+	}
+	C.set_obj_fn(l.ptr, &cRow[0])
+
+	if maximize {
+		C.set_maxim(l.ptr)
+	}
+}
+
+type SolutionType int
+
+// Values match the solve() return codes in lp_lib.h
+const (
+	NOMEMORY    SolutionType = -2
+	NOTRUN      SolutionType = -1
+	OPTIMAL     SolutionType = 0
+	SUBOPTIMAL  SolutionType = 1
+	INFEASIBLE  SolutionType = 2
+	UNBOUNDED   SolutionType = 3
+	DEGENERATE  SolutionType = 4
+	NUMFAILURE  SolutionType = 5
+	USERABORT   SolutionType = 6
+	TIMEOUT     SolutionType = 7
+	PRESOLVED   SolutionType = 9
+	PROCFAIL    SolutionType = 10
+	PROCBREAK   SolutionType = 11
+	FEASFOUND   SolutionType = 12
+	NOFEASFOUND SolutionType = 13
+)
+
+var solutionTypeNames = map[SolutionType]string{
+	NOMEMORY:    "NOMEMORY",
+	NOTRUN:      "NOTRUN",
+	OPTIMAL:     "OPTIMAL",
+	SUBOPTIMAL:  "SUBOPTIMAL",
+	INFEASIBLE:  "INFEASIBLE",
+	UNBOUNDED:   "UNBOUNDED",
+	DEGENERATE:  "DEGENERATE",
+	NUMFAILURE:  "NUMFAILURE",
+	USERABORT:   "USERABORT",
+	TIMEOUT:     "TIMEOUT",
+	PRESOLVED:   "PRESOLVED",
+	PROCFAIL:    "PROCFAIL",
+	PROCBREAK:   "PROCBREAK",
+	FEASFOUND:   "FEASFOUND",
+	NOFEASFOUND: "NOFEASFOUND",
+}
+
+func (s SolutionType) String() string {
+	if name, ok := solutionTypeNames[s]; ok {
+		return name
+	}
+	return "SolutionType(" + strconv.Itoa(int(s)) + ")"
+}
+
+func (l *LP) Solve() SolutionType {
+	return SolutionType(C.solve(l.ptr))
+}
+
+func (l *LP) WriteToStdout() {
+	C.write_LP(l.ptr, C.stdout)
+}
+
+func (l *LP) WriteToString() string {
+	cstr := C.write_lp_to_str(l.ptr)
+	str := C.GoString(cstr)
+	C.free(unsafe.Pointer(cstr))
+	return str
+}
+
+func (l *LP) GetObjective() float64 {
+	return float64(C.get_objective(l.ptr))
+}
+
+func (l *LP) GetVariables() []float64 {
+	numCols := int(C.get_Ncolumns(l.ptr))
+	cRow := make([]C.double, numCols)
+	C.get_variables(l.ptr, &cRow[0])
+	row := make([]float64, numCols)
+	for i := 0; i < numCols; i++ {
+		row[i] = float64(cRow[i])
+	}
+	return row
+}
@@ -0,0 +1,148 @@
+package golp
+
+import (
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestLP(t *testing.T) {
+	Convey("It solves a linear program", t, func() {
+		lp := NewLP(0, 2)
+		lp.SetVerboseLevel(NEUTRAL)
+		lp.SetColName(0, "x")
+		lp.SetColName(1, "y")
+		So(lp.GetColName(0), ShouldEqual, "x")
+		So(lp.GetColName(1), ShouldEqual, "y")
+
+		lp.AddConstraint([]float64{120.0, 210.0}, LE, 15000)
+		lp.AddConstraintSparse([]Entry{Entry{Col: 0, Val: 110.0}, Entry{Col: 1, Val: 30.0}}, LE, 4000)
+		lp.AddConstraintSparse([]Entry{Entry{Col: 1, Val: 1.0}, Entry{Col: 0, Val: 1.0}}, LE, 75)
+
+		lp.SetObjFn([]float64{143, 60}, true)
+
+		lpString := "/* Objective function */\nmax: +143 x +60 y;\n\n/* Constraints */\n+120 x +210 y <= 15000;\n+110 x +30 y <= 4000;\n+x +y <= 75;\n"
+		So(lp.WriteToString(), ShouldEqual, lpString)
+
+		So(lp.Solve(), ShouldEqual, OPTIMAL)
+
+		delta := 0.000001
+		So(lp.GetObjective(), ShouldAlmostEqual, 6315.625, delta)
+
+		vars := lp.GetVariables()
+		So(len(vars), ShouldEqual, 2)
+		So(vars[0], ShouldAlmostEqual, 21.875, delta)
+		So(vars[1], ShouldAlmostEqual, 53.125, delta)
+	})
+}
+
+func TestIntegerLP(t *testing.T) {
+	Convey("It solves an integer program", t, func() {
+		lp := NewLP(0, 2)
+		lp.SetVerboseLevel(NEUTRAL)
+		lp.AddConstraint([]float64{120.0, 210.0}, LE, 15000)
+		lp.AddConstraintSparse([]Entry{Entry{Col: 0, Val: 110.0}, Entry{Col: 1, Val: 30.0}}, LE, 4000)
+		lp.AddConstraintSparse([]Entry{Entry{Col: 1, Val: 1.0}, Entry{Col: 0, Val: 1.0}}, LE, 75)
+		lp.SetInt(0, true)
+		lp.SetInt(1, true)
+		So(lp.IsInt(0), ShouldBeTrue)
+		So(lp.IsInt(1), ShouldBeTrue)
+
+		lp.SetObjFn([]float64{143, 60}, true)
+		lp.Solve()
+
+		delta := 0.000001
+		So(lp.GetObjective(), ShouldAlmostEqual, 6266, delta)
+
+		vars := lp.GetVariables()
+		So(vars[0], ShouldAlmostEqual, 22, delta)
+		So(vars[1], ShouldAlmostEqual, 52, delta)
+	})
+}
+
+func TestInfeasibleLP(t *testing.T) {
+	Convey("It reports an infeasible program", t, func() {
+		lp := NewLP(0, 1)
+		lp.SetVerboseLevel(NEUTRAL)
+		lp.AddConstraint([]float64{1.0}, GE, 2)
+		lp.AddConstraint([]float64{1.0}, LE, 1)
+		lp.SetObjFn([]float64{1.0}, true)
+
+		ret := lp.Solve()
+		So(ret, ShouldEqual, INFEASIBLE)
+		So(ret.String(), ShouldEqual, "INFEASIBLE")
+		So(SolutionType(42).String(), ShouldEqual, "SolutionType(42)")
+	})
+}
+
+func TestBinaryAndBoundsLP(t *testing.T) {
+	Convey("It solves a program with binary and bounded columns", t, func() {
+		lp := NewLP(0, 3)
+		lp.SetVerboseLevel(NEUTRAL)
+		lp.AddConstraint([]float64{3.0, 2.0, 2.0}, LE, 4)
+		lp.SetBinary(0, true)
+		lp.SetBinary(1, true)
+		lp.SetBounds(2, 0, 0.5)
+		So(lp.IsBinary(0), ShouldBeTrue)
+		So(lp.IsBinary(2), ShouldBeFalse)
+
+		lower, upper := lp.GetBounds(2)
+		So(lower, ShouldEqual, 0.0)
+		So(upper, ShouldEqual, 0.5)
+
+		lp.SetBreakAtFirst(true)
+		So(lp.IsBreakAtFirst(), ShouldBeTrue)
+		lp.SetBreakAtFirst(false)
+
+		lp.SetObjFn([]float64{5, 3, 4}, true)
+		So(lp.Solve(), ShouldEqual, OPTIMAL)
+
+		delta := 0.000001
+		So(lp.GetObjective(), ShouldAlmostEqual, 7, delta)
+
+		vars := lp.GetVariables()
+		So(vars[0], ShouldAlmostEqual, 1, delta)
+		So(vars[1], ShouldAlmostEqual, 0, delta)
+		So(vars[2], ShouldAlmostEqual, 0.5, delta)
+	})
+}
+
+func TestTimeoutAndAbortLP(t *testing.T) {
+	// lp_solve can't solve a program again once it's been aborted, so each solve gets a new one
+	newLP := func() *LP {
+		lp := NewLP(0, 2)
+		lp.SetVerboseLevel(NEUTRAL)
+		lp.AddConstraintSparse([]Entry{Entry{Col: 0, Val: 2.0}, Entry{Col: 1, Val: 2.0}}, LE, 3)
+		lp.SetInt(0, true)
+		lp.SetInt(1, true)
+		lp.SetObjFn([]float64{1, 1}, true)
+		return lp
+	}
+
+	Convey("It calls the abort function while solving", t, func() {
+		lp := newLP()
+		lp.SetTimeout(5)
+		So(lp.GetTimeout(), ShouldEqual, 5)
+		calls := 0
+		lp.SetAbortFunc(func() bool {
+			calls++
+			return false
+		})
+		So(lp.Solve(), ShouldEqual, OPTIMAL)
+		So(calls, ShouldBeGreaterThan, 0)
+		So(lp.GetObjective(), ShouldAlmostEqual, 1, 0.000001)
+	})
+
+	Convey("It stops when the abort function returns true", t, func() {
+		lp := newLP()
+		lp.SetAbortFunc(func() bool { return true })
+		So(lp.Solve(), ShouldEqual, USERABORT)
+	})
+
+	Convey("A nil abort function removes it", t, func() {
+		lp := newLP()
+		lp.SetAbortFunc(func() bool { return true })
+		lp.SetAbortFunc(nil)
+		So(lp.Solve(), ShouldEqual, OPTIMAL)
+	})
+}
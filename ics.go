@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"crypto/sha1"
+	"encoding/hex"
+	"strconv"
+	"strings"
+	. "time"
+)
+
+// Render the task events as an RFC 5545 iCalendar with the events in the time zone of the params
+func (tp *TaskParams) taskScheduleICS() []byte {
+	var b bytes.Buffer
+	writeICSLine(&b, "BEGIN:VCALENDAR")
+	writeICSLine(&b, "VERSION:2.0")
+	writeICSLine(&b, "PRODID:-//wizweek//scheduler//EN")
+	writeICSLine(&b, "CALSCALE:GREGORIAN")
+	writeICSLine(&b, "METHOD:PUBLISH")
+	tp.writeVTimezone(&b)
+
+	dtstamp := Now().UTC().Format(icsUTCLayout)
+	chunksByTask := make(map[*Task]int)
+	for _, event := range tp.TaskEvents {
+		chunksByTask[event.Task]++
+		writeICSLine(&b, "BEGIN:VEVENT")
+		writeICSLine(&b, "UID:"+taskEventUID(event.Task, chunksByTask[event.Task]))
+		writeICSLine(&b, "DTSTAMP:"+dtstamp)
+		writeICSLine(&b, "DTSTART;TZID="+tp.TimeZoneName+":"+event.Start.In(tp.Location).Format(icsLocalLayout))
+		writeICSLine(&b, "DTEND;TZID="+tp.TimeZoneName+":"+event.End.In(tp.Location).Format(icsLocalLayout))
+		writeICSLine(&b, "SUMMARY:"+escapeICSText(event.Title))
+		description := "finish: " + strconv.FormatBool(event.Finish)
+		if event.Assignee != "" {
+			description += "\nassignee: " + event.Assignee
+		}
+		writeICSLine(&b, "DESCRIPTION:"+escapeICSText(description))
+		writeICSLine(&b, "TRANSP:OPAQUE")
+		writeICSLine(&b, "END:VEVENT")
+	}
+
+	writeICSLine(&b, "END:VCALENDAR")
+	return b.Bytes()
+}
+
+const (
+	icsUTCLayout   = "20060102T150405Z"
+	icsLocalLayout = "20060102T150405"
+)
+
+// A UID that stays the same across runs for the same chunk (nth event) of the same task, so that
+// calendars importing the schedule again update the events rather than duplicating them. A task
+// without an id is known by its title and place in the request, as titles can be shared.
+func taskEventUID(task *Task, chunk int) string {
+	key := task.ID
+	if key == "" {
+		key = strconv.Itoa(task.requestIndex) + ":" + task.Title
+	}
+	sum := sha1.Sum([]byte(key))
+	return hex.EncodeToString(sum[:8]) + "-" + strconv.Itoa(chunk) + "@wizweek-scheduler"
+}
+
+// Write a VTIMEZONE for the params time zone with an observance for each offset change in effect
+// from the start to the end of the schedule. Go doesn't expose the zone rules, so the changes are
+// found by searching for when the offset changes.
+func (tp *TaskParams) writeVTimezone(b *bytes.Buffer) {
+	start := tp.StartTaskSchedule
+	if len(tp.TaskEvents) > 0 && tp.TaskEvents[0].Start.Before(start) {
+		start = tp.TaskEvents[0].Start
+	}
+	end := tp.EndTaskSchedule
+	if len(tp.TaskEvents) > 0 && tp.TaskEvents[len(tp.TaskEvents)-1].End.After(end) {
+		end = tp.TaskEvents[len(tp.TaskEvents)-1].End
+	}
+
+	writeICSLine(b, "BEGIN:VTIMEZONE")
+	writeICSLine(b, "TZID:"+tp.TimeZoneName)
+
+	// The observance in effect at the start began at the last change before it, if there was one
+	// within the year before.
+	transition, found := lastZoneTransition(start.Add(-366*24*Hour), start, tp.Location)
+	if found {
+		writeObservance(b, transition, tp.Location)
+	} else {
+		_, offset := start.In(tp.Location).Zone()
+		writeObservanceAt(b, Date(1970, 1, 1, 0, 0, 0, 0, FixedZone("", offset)), offset, start, tp.Location)
+	}
+
+	for t := start; t.Before(end); {
+		transition, found = nextZoneTransition(t, end, tp.Location)
+		if !found {
+			break
+		}
+		writeObservance(b, transition, tp.Location)
+		t = transition
+	}
+
+	writeICSLine(b, "END:VTIMEZONE")
+}
+
+// Write the observance starting at the transition instant, which is the first instant in the new offset
+func writeObservance(b *bytes.Buffer, transition Time, loc *Location) {
+	_, offsetFrom := transition.Add(-Second).In(loc).Zone()
+	writeObservanceAt(b, transition, offsetFrom, transition, loc)
+}
+
+func writeObservanceAt(b *bytes.Buffer, start Time, offsetFrom int, inEffect Time, loc *Location) {
+	name, offsetTo := inEffect.In(loc).Zone()
+	kind := "STANDARD"
+	if inEffect.In(loc).IsDST() {
+		kind = "DAYLIGHT"
+	}
+	writeICSLine(b, "BEGIN:"+kind)
+	// The start is given in the local time of the offset in effect before it
+	writeICSLine(b, "DTSTART:"+start.In(FixedZone("", offsetFrom)).Format(icsLocalLayout))
+	writeICSLine(b, "TZOFFSETFROM:"+formatICSOffset(offsetFrom))
+	writeICSLine(b, "TZOFFSETTO:"+formatICSOffset(offsetTo))
+	writeICSLine(b, "TZNAME:"+name)
+	writeICSLine(b, "END:"+kind)
+}
+
+// Find the first instant after t (and no later than end) with a different offset than at t
+func nextZoneTransition(t, end Time, loc *Location) (Time, bool) {
+	_, offset := t.In(loc).Zone()
+	for day := t; day.Before(end); day = day.Add(24 * Hour) {
+		next := day.Add(24 * Hour)
+		if next.After(end) {
+			next = end
+		}
+		if _, nextOffset := next.In(loc).Zone(); nextOffset != offset {
+			return searchZoneTransition(day, next, loc), true
+		}
+	}
+	return Time{}, false
+}
+
+func lastZoneTransition(start, t Time, loc *Location) (Time, bool) {
+	var last Time
+	found := false
+	for {
+		transition, ok := nextZoneTransition(start, t, loc)
+		if !ok {
+			return last, found
+		}
+		last, found = transition, true
+		start = transition
+	}
+}
+
+// Binary search for the first instant in (before, after] with the offset at after
+func searchZoneTransition(before, after Time, loc *Location) Time {
+	_, offset := after.In(loc).Zone()
+	for after.Sub(before) > Second {
+		mid := before.Add(after.Sub(before) / 2).Truncate(Second)
+		if _, midOffset := mid.In(loc).Zone(); midOffset == offset {
+			after = mid
+		} else {
+			before = mid
+		}
+	}
+	return after
+}
+
+func formatICSOffset(offset int) string {
+	sign := "+"
+	if offset < 0 {
+		sign = "-"
+		offset = -offset
+	}
+	s := sign + twoDigits(offset/3600) + twoDigits(offset%3600/60)
+	if offset%60 != 0 {
+		s += twoDigits(offset % 60)
+	}
+	return s
+}
+
+func twoDigits(n int) string {
+	if n < 10 {
+		return "0" + strconv.Itoa(n)
+	}
+	return strconv.Itoa(n)
+}
+
+var icsTextEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`)
+
+func escapeICSText(s string) string {
+	return icsTextEscaper.Replace(s)
+}
+
+// Write a content line followed by CRLF, folding it so no line is longer than 75 octets
+func writeICSLine(b *bytes.Buffer, line string) {
+	const maxLen = 75
+	limit := maxLen
+	for len(line) > limit {
+		// Don't split a multi-byte UTF-8 character
+		cut := limit
+		for cut > 0 && line[cut]&0xC0 == 0x80 {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = maxLen - 1 // Allow for the leading space of the continuation line
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
@@ -0,0 +1,101 @@
+package main
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	. "time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestScheduleICS(t *testing.T) {
+	in := []byte(`{
+		"timeZone": "America/New_York",
+		"weeklyTaskBlocks": [
+			[],
+			[{"start": "10:00", "end": "12:00"}],
+			[{"start": "9:00", "end": "10:00"}, {"start": "11:30", "end": "14:30"}],
+			[],
+			[],
+			[],
+			[]
+		],
+		"appointments": [	],
+		"tasks": [
+			{"title": "Newsletter, draft; and send", "estimatedHours": 2, "reward": 9},
+			{"id": "mpd", "title": "MPD", "estimatedHours": 3, "reward": 6}
+		],
+		"startTaskSchedule": "2015-03-02T14:00:00Z",
+		"endTaskSchedule": "2015-03-10T22:00:00Z"
+	}`)
+
+	Convey("The task events are rendered as an iCalendar with a time zone and stable UIDs", t, func() {
+		tp, err := computeSchedule(in)
+		So(err, ShouldBeNil)
+		So(len(tp.TaskEvents), ShouldEqual, 3)
+
+		ics := string(tp.taskScheduleICS())
+		So(strings.HasPrefix(ics, "BEGIN:VCALENDAR\r\nVERSION:2.0\r\n"), ShouldBeTrue)
+		So(strings.HasSuffix(ics, "END:VCALENDAR\r\n"), ShouldBeTrue)
+
+		// Eastern time changes to daylight time on March 8th 2015
+		So(ics, ShouldContainSubstring, "BEGIN:VTIMEZONE\r\nTZID:America/New_York\r\n"+
+			"BEGIN:STANDARD\r\nDTSTART:20141102T020000\r\nTZOFFSETFROM:-0400\r\nTZOFFSETTO:-0500\r\nTZNAME:EST\r\nEND:STANDARD\r\n"+
+			"BEGIN:DAYLIGHT\r\nDTSTART:20150308T020000\r\nTZOFFSETFROM:-0500\r\nTZOFFSETTO:-0400\r\nTZNAME:EDT\r\nEND:DAYLIGHT\r\n"+
+			"END:VTIMEZONE\r\n")
+
+		So(ics, ShouldContainSubstring, "DTSTART;TZID=America/New_York:20150302T100000\r\n"+
+			"DTEND;TZID=America/New_York:20150302T120000\r\n"+
+			`SUMMARY:Newsletter\, draft\; and send`+"\r\nDESCRIPTION:finish: true\r\n")
+		So(ics, ShouldContainSubstring, "DTSTART;TZID=America/New_York:20150303T090000\r\n"+
+			"DTEND;TZID=America/New_York:20150303T100000\r\nSUMMARY:MPD\r\nDESCRIPTION:finish: false\r\n")
+		So(ics, ShouldContainSubstring, "DTSTART;TZID=America/New_York:20150303T113000\r\n"+
+			"DTEND;TZID=America/New_York:20150303T133000\r\nSUMMARY:MPD\r\nDESCRIPTION:finish: true\r\n")
+
+		So(ics, ShouldContainSubstring, "UID:"+taskEventUID(&tp.Tasks[1], 1)+"\r\n")
+		So(ics, ShouldContainSubstring, "UID:"+taskEventUID(&tp.Tasks[1], 2)+"\r\n")
+		So(taskEventUID(&tp.Tasks[1], 1), ShouldEqual, taskEventUID(&Task{ID: "mpd"}, 1))
+		So(taskEventUID(&tp.Tasks[1], 1), ShouldNotEqual, taskEventUID(&tp.Tasks[1], 2))
+	})
+
+	Convey("Tasks without an id that share a title have different UIDs", t, func() {
+		params := strings.Replace(string(in), `{"id": "mpd", "title": "MPD"`, `{"title": "Newsletter, draft; and send"`, 1)
+		tp, err := computeSchedule([]byte(params))
+		So(err, ShouldBeNil)
+		So(len(tp.TaskEvents), ShouldEqual, 3)
+
+		ics := string(tp.taskScheduleICS())
+		uids := make(map[string]bool)
+		for _, line := range strings.Split(ics, "\r\n") {
+			if strings.HasPrefix(line, "UID:") {
+				So(uids[line], ShouldBeFalse)
+				uids[line] = true
+			}
+		}
+		So(len(uids), ShouldEqual, 3)
+		So(taskEventUID(&tp.Tasks[0], 1), ShouldNotEqual, taskEventUID(&tp.Tasks[1], 1))
+	})
+
+	Convey("Long lines are folded at 75 octets", t, func() {
+		longTitle := strings.Repeat("é", 50)
+		start := Date(2015, 3, 2, 14, 0, 0, 0, UTC)
+		tp := TaskParams{TimeZoneName: "UTC", Location: UTC, StartTaskSchedule: start, EndTaskSchedule: start.Add(Hour)}
+		tp.TaskEvents = []TaskEvent{{Task: &Task{Title: longTitle}, Title: longTitle, Start: start, End: start.Add(Hour)}}
+		ics := string(tp.taskScheduleICS())
+		for _, line := range strings.Split(ics, "\r\n") {
+			So(len(line), ShouldBeLessThanOrEqualTo, 75)
+		}
+		So(strings.Replace(ics, "\r\n ", "", -1), ShouldContainSubstring, "SUMMARY:"+longTitle+"\r\n")
+	})
+
+	Convey("The handler returns iCalendar for ?format=ics", t, func() {
+		r := httptest.NewRequest("POST", "/?format=ics", strings.NewReader(string(in)))
+		w := httptest.NewRecorder()
+		computeScheduleHandler(w, r)
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(w.Header().Get("Content-Type"), ShouldStartWith, "text/calendar")
+		So(w.Body.String(), ShouldStartWith, "BEGIN:VCALENDAR")
+	})
+}
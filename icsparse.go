@@ -0,0 +1,338 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	. "time"
+)
+
+// A VEVENT from an uploaded iCalendar, before its recurrences are expanded
+type icsEvent struct {
+	uid          string
+	summary      string
+	start        Time
+	end          Time
+	isDate       bool
+	hasEnd       bool
+	durDays      int
+	dur          Duration
+	rrule        string
+	exdates      []Time
+	recurrenceID Time
+	transparent  bool
+	cancelled    bool
+}
+
+// An iCalendar content line, e.g. DTSTART;TZID=America/New_York:20150302T090000
+type icsProperty struct {
+	name   string
+	params map[string]string
+	value  string
+}
+
+// Parse the VEVENTs of an iCalendar into appointments within the schedule window. Recurring events
+// are expanded with EXDATEs and modified instances (RECURRENCE-ID) taken into account, and
+// transparent (free) and cancelled events are left out since they don't block any time.
+func (tp *TaskParams) parseAppointmentsICS(data string) ([]Appointment, error) {
+	events, err := parseICSEvents(data, tp.Location)
+	if err != nil {
+		return nil, fieldError("appointmentsIcs", "is invalid: "+err.Error())
+	}
+
+	// Instances of recurring events that were moved or cancelled, by UID
+	overridden := make(map[string][]Time)
+	for _, event := range events {
+		if !event.recurrenceID.IsZero() {
+			overridden[event.uid] = append(overridden[event.uid], event.recurrenceID)
+		}
+	}
+
+	appts := make([]Appointment, 0)
+	for _, event := range events {
+		if event.transparent || event.cancelled {
+			continue
+		}
+		end := event.endFor(event.start)
+		if event.rrule == "" || !event.recurrenceID.IsZero() {
+			if tp.inScheduleWindow(event.start, end) {
+				appts = append(appts, Appointment{Title: event.summary, Start: event.start, End: end})
+			}
+			continue
+		}
+
+		rule, err := parseRRule(event.rrule, event.start.Location())
+		if err != nil {
+			return nil, fieldError("appointmentsIcs", "is invalid: "+event.summary+": "+err.Error())
+		}
+		excluded := append(append([]Time{}, event.exdates...), overridden[event.uid]...)
+		rule.each(event.start, func(start Time) bool {
+			if !start.Before(tp.EndTaskSchedule) {
+				return false
+			}
+			end := event.endFor(start)
+			if tp.inScheduleWindow(start, end) && !containsTime(excluded, start) {
+				appts = append(appts, Appointment{Title: event.summary, Start: start, End: end})
+			}
+			return true
+		})
+	}
+
+	sort.SliceStable(appts, func(i, j int) bool { return appts[i].Start.Before(appts[j].Start) })
+	return appts, nil
+}
+
+func (tp TaskParams) inScheduleWindow(start, end Time) bool {
+	return start.Before(tp.EndTaskSchedule) && end.After(tp.StartTaskSchedule)
+}
+
+func containsTime(times []Time, t Time) bool {
+	for _, other := range times {
+		if other.Equal(t) {
+			return true
+		}
+	}
+	return false
+}
+
+// The end of the event (or of an instance of it) starting at start. With neither a DTEND nor a
+// DURATION an all-day event lasts the day and a timed event ends when it starts.
+func (event icsEvent) endFor(start Time) Time {
+	switch {
+	case event.hasEnd:
+		if event.isDate {
+			days := int(utcDate(event.end).Sub(utcDate(event.start)) / (24 * Hour))
+			return start.AddDate(0, 0, days)
+		}
+		return start.Add(event.end.Sub(event.start))
+	case event.isDate && event.durDays == 0 && event.dur == 0:
+		return start.AddDate(0, 0, 1)
+	default:
+		return start.AddDate(0, 0, event.durDays).Add(event.dur)
+	}
+}
+
+func utcDate(t Time) Time {
+	year, month, day := t.Date()
+	return Date(year, month, day, 0, 0, 0, 0, UTC)
+}
+
+func parseICSEvents(data string, loc *Location) ([]icsEvent, error) {
+	events := make([]icsEvent, 0)
+	var event *icsEvent
+	// Components nested in a VEVENT (like a VALARM) have properties that aren't the event's
+	nested := 0
+
+	for _, line := range unfoldICSLines(data) {
+		if strings.TrimSpace(line) == "" {
+			continue
+		}
+		prop, err := parseICSProperty(line)
+		if err != nil {
+			return nil, err
+		}
+
+		switch {
+		case prop.name == "BEGIN" && strings.ToUpper(prop.value) == "VEVENT":
+			event = &icsEvent{}
+			nested = 0
+			continue
+		case prop.name == "END" && strings.ToUpper(prop.value) == "VEVENT":
+			if event == nil {
+				return nil, errors.New("END:VEVENT without BEGIN:VEVENT")
+			}
+			if event.start.IsZero() {
+				return nil, errors.New("VEVENT without DTSTART: " + event.summary)
+			}
+			events = append(events, *event)
+			event = nil
+			continue
+		case event == nil:
+			continue
+		case prop.name == "BEGIN":
+			nested++
+			continue
+		case prop.name == "END":
+			nested--
+			continue
+		case nested > 0:
+			continue
+		}
+
+		if err := event.setProperty(prop, loc); err != nil {
+			return nil, err
+		}
+	}
+	if event != nil {
+		return nil, errors.New("VEVENT without END:VEVENT: " + event.summary)
+	}
+	return events, nil
+}
+
+func (event *icsEvent) setProperty(prop icsProperty, loc *Location) error {
+	var err error
+	switch prop.name {
+	case "UID":
+		event.uid = prop.value
+	case "SUMMARY":
+		event.summary = unescapeICSText(prop.value)
+	case "DTSTART":
+		event.start, event.isDate, err = parseICSTime(prop, loc)
+	case "DTEND":
+		event.end, _, err = parseICSTime(prop, loc)
+		event.hasEnd = true
+	case "DURATION":
+		event.durDays, event.dur, err = parseICSDuration(prop.value)
+	case "RRULE":
+		event.rrule = prop.value
+	case "EXDATE":
+		for _, value := range strings.Split(prop.value, ",") {
+			var exdate Time
+			exdate, _, err = parseICSTime(icsProperty{prop.name, prop.params, value}, loc)
+			if err != nil {
+				break
+			}
+			event.exdates = append(event.exdates, exdate)
+		}
+	case "RECURRENCE-ID":
+		event.recurrenceID, _, err = parseICSTime(prop, loc)
+	case "TRANSP":
+		event.transparent = strings.ToUpper(prop.value) == "TRANSPARENT"
+	case "STATUS":
+		event.cancelled = strings.ToUpper(prop.value) == "CANCELLED"
+	}
+	if err != nil {
+		return errors.New(prop.name + " of " + event.summary + ": " + err.Error())
+	}
+	return nil
+}
+
+// Join folded lines, i.e. those continued on a following line that starts with a space or tab
+func unfoldICSLines(data string) []string {
+	data = strings.Replace(data, "\r\n", "\n", -1)
+	data = strings.Replace(data, "\n ", "", -1)
+	data = strings.Replace(data, "\n\t", "", -1)
+	return strings.Split(data, "\n")
+}
+
+func parseICSProperty(line string) (icsProperty, error) {
+	// The value starts after the first colon that isn't inside a quoted parameter value
+	inQuotes := false
+	colon := -1
+	for i := 0; i < len(line) && colon < 0; i++ {
+		switch line[i] {
+		case '"':
+			inQuotes = !inQuotes
+		case ':':
+			if !inQuotes {
+				colon = i
+			}
+		}
+	}
+	if colon < 0 {
+		return icsProperty{}, errors.New("invalid line: " + line)
+	}
+
+	parts := strings.Split(line[:colon], ";")
+	prop := icsProperty{name: strings.ToUpper(parts[0]), params: make(map[string]string), value: line[colon+1:]}
+	for _, param := range parts[1:] {
+		nameValue := strings.SplitN(param, "=", 2)
+		if len(nameValue) == 2 {
+			prop.params[strings.ToUpper(nameValue[0])] = strings.Trim(nameValue[1], `"`)
+		}
+	}
+	return prop, nil
+}
+
+// Parse a date or date-time property value using its TZID, if any. Times without a TZID or a
+// trailing Z (floating times) and dates are taken to be in loc.
+func parseICSTime(prop icsProperty, loc *Location) (Time, bool, error) {
+	if tzid, ok := prop.params["TZID"]; ok {
+		// Some calendars use zone names that aren't in the time zone database, e.g. Outlook's
+		// Windows names, in which case the params time zone is the best guess.
+		if tzLoc, err := LoadLocation(tzid); err == nil {
+			loc = tzLoc
+		}
+	}
+	t, isDate, err := parseICSDateTime(prop.value, loc)
+	if err == nil && prop.params["VALUE"] == "DATE" && !isDate {
+		err = errors.New("expected a date: " + prop.value)
+	}
+	return t, isDate, err
+}
+
+// Parse an iCalendar DATE (20150302) or DATE-TIME (20150302T090000 or 20150302T140000Z), returning
+// whether it was a date
+func parseICSDateTime(value string, loc *Location) (Time, bool, error) {
+	value = strings.TrimSpace(value)
+	switch {
+	case len(value) == len("20060102"):
+		t, err := ParseInLocation("20060102", value, loc)
+		return t, true, err
+	case strings.HasSuffix(value, "Z"):
+		t, err := Parse(icsUTCLayout, value)
+		return t, false, err
+	default:
+		t, err := ParseInLocation(icsLocalLayout, value, loc)
+		return t, false, err
+	}
+}
+
+// Parse a DURATION like PT1H30M or P1D into its days and the rest. Days are kept separate since
+// they are calendar days, which aren't always 24 hours.
+func parseICSDuration(value string) (days int, dur Duration, err error) {
+	invalid := errors.New("invalid duration: " + value)
+	s := strings.ToUpper(strings.TrimSpace(value))
+	sign := 1
+	if strings.HasPrefix(s, "-") {
+		sign = -1
+	}
+	s = strings.TrimLeft(s, "+-")
+	if !strings.HasPrefix(s, "P") {
+		return 0, 0, invalid
+	}
+	s = s[1:]
+
+	inTime := false
+	num := ""
+	for _, c := range s {
+		switch {
+		case c >= '0' && c <= '9':
+			num += string(c)
+			continue
+		case c == 'T':
+			inTime = true
+			continue
+		}
+		n, err := strconv.Atoi(num)
+		if err != nil {
+			return 0, 0, invalid
+		}
+		num = ""
+		switch {
+		case c == 'W' && !inTime:
+			days += 7 * n
+		case c == 'D' && !inTime:
+			days += n
+		case c == 'H' && inTime:
+			dur += Duration(n) * Hour
+		case c == 'M' && inTime:
+			dur += Duration(n) * Minute
+		case c == 'S' && inTime:
+			dur += Duration(n) * Second
+		default:
+			return 0, 0, invalid
+		}
+	}
+	if num != "" {
+		return 0, 0, invalid
+	}
+	return sign * days, Duration(sign) * dur, nil
+}
+
+var icsTextUnescaper = strings.NewReplacer(`\\`, `\`, `\;`, ";", `\,`, ",", `\n`, "\n", `\N`, "\n")
+
+func unescapeICSText(s string) string {
+	return icsTextUnescaper.Replace(s)
+}
@@ -0,0 +1,206 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	. "time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRRule(t *testing.T) {
+	ny, _ := LoadLocation("America/New_York")
+	occurrences := func(value string, dtstart Time, max int) []string {
+		rule, err := parseRRule(value, dtstart.Location())
+		So(err, ShouldBeNil)
+		times := make([]string, 0)
+		rule.each(dtstart, func(t Time) bool {
+			times = append(times, t.Format("2006-01-02 15:04 MST"))
+			return len(times) < max
+		})
+		return times
+	}
+
+	Convey("Weekly rules keep the local time across a daylight saving change", t, func() {
+		dtstart := Date(2015, 3, 2, 9, 0, 0, 0, ny)
+		So(occurrences("FREQ=WEEKLY;BYDAY=MO,TH;COUNT=4", dtstart, 10), ShouldResemble, []string{
+			"2015-03-02 09:00 EST", "2015-03-05 09:00 EST", "2015-03-09 09:00 EDT", "2015-03-12 09:00 EDT",
+		})
+		So(occurrences("FREQ=WEEKLY;INTERVAL=2;UNTIL=20150317T000000Z", dtstart, 10), ShouldResemble, []string{
+			"2015-03-02 09:00 EST", "2015-03-16 09:00 EDT",
+		})
+	})
+
+	Convey("Daily rules can be limited by weekday", t, func() {
+		dtstart := Date(2015, 3, 5, 8, 30, 0, 0, UTC)
+		So(occurrences("FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR", dtstart, 4), ShouldResemble, []string{
+			"2015-03-05 08:30 UTC", "2015-03-06 08:30 UTC", "2015-03-09 08:30 UTC", "2015-03-10 08:30 UTC",
+		})
+	})
+
+	Convey("Monthly rules support month days and weekday ordinals", t, func() {
+		dtstart := Date(2015, 1, 31, 12, 0, 0, 0, UTC)
+		// Months without a 31st are skipped
+		So(occurrences("FREQ=MONTHLY;COUNT=3", dtstart, 10), ShouldResemble, []string{
+			"2015-01-31 12:00 UTC", "2015-03-31 12:00 UTC", "2015-05-31 12:00 UTC",
+		})
+		So(occurrences("FREQ=MONTHLY;BYMONTHDAY=-1", dtstart, 3), ShouldResemble, []string{
+			"2015-01-31 12:00 UTC", "2015-02-28 12:00 UTC", "2015-03-31 12:00 UTC",
+		})
+		So(occurrences("FREQ=MONTHLY;BYDAY=1MO,-1FR", dtstart, 4), ShouldResemble, []string{
+			"2015-02-02 12:00 UTC", "2015-02-27 12:00 UTC", "2015-03-02 12:00 UTC", "2015-03-27 12:00 UTC",
+		})
+		So(occurrences("FREQ=MONTHLY;BYDAY=FR;BYMONTHDAY=13", dtstart, 2), ShouldResemble, []string{
+			"2015-02-13 12:00 UTC", "2015-03-13 12:00 UTC",
+		})
+	})
+
+	Convey("Yearly rules expand by month", t, func() {
+		dtstart := Date(2015, 1, 15, 0, 0, 0, 0, UTC)
+		So(occurrences("FREQ=YEARLY;BYMONTH=1,7;COUNT=3", dtstart, 10), ShouldResemble, []string{
+			"2015-01-15 00:00 UTC", "2015-07-15 00:00 UTC", "2016-01-15 00:00 UTC",
+		})
+	})
+
+	Convey("Invalid rules are rejected", t, func() {
+		for _, value := range []string{"BYDAY=MO", "FREQ=HOURLY", "FREQ=WEEKLY;BYDAY=XX", "FREQ=DAILY;INTERVAL=0",
+			"FREQ=MONTHLY;BYMONTHDAY=32", "FREQ=DAILY;BYSETPOS=1"} {
+			_, err := parseRRule(value, UTC)
+			So(err, ShouldNotBeNil)
+		}
+	})
+}
+
+const testAppointmentsICS = "BEGIN:VCALENDAR\r\n" +
+	"VERSION:2.0\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:standup@example.com\r\n" +
+	"SUMMARY:Standup\\, daily\r\n" +
+	"DTSTART;TZID=America/New_York:20150302T093000\r\n" +
+	"DURATION:PT30M\r\n" +
+	"RRULE:FREQ=DAILY;BYDAY=MO,TU,WE,TH,FR\r\n" +
+	"EXDATE;TZID=America/New_York:20150304T093000\r\n" +
+	"BEGIN:VALARM\r\n" +
+	"ACTION:DISPLAY\r\n" +
+	"DESCRIPTION:Reminder\r\n" +
+	"END:VALARM\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:standup@example.com\r\n" +
+	"SUMMARY:Standup\\, moved\r\n" +
+	"RECURRENCE-ID;TZID=America/New_York:20150305T093000\r\n" +
+	"DTSTART;TZID=America/New_York:20150305T160000\r\n" +
+	"DTEND;TZID=America/New_York:20150305T163000\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:lunch@example.com\r\n" +
+	"SUMMARY:Lunch (free)\r\n" +
+	"DTSTART:20150302T170000Z\r\n" +
+	"DTEND:20150302T180000Z\r\n" +
+	"TRANSP:TRANSPARENT\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:offsite@example.com\r\n" +
+	"SUMMARY:Team offsite with a long description that is folded onto the\r\n" +
+	"  next line\r\n" +
+	"DTSTART;VALUE=DATE:20150306\r\n" +
+	"DTEND;VALUE=DATE:20150307\r\n" +
+	"END:VEVENT\r\n" +
+	"BEGIN:VEVENT\r\n" +
+	"UID:cancelled@example.com\r\n" +
+	"SUMMARY:Cancelled\r\n" +
+	"DTSTART:20150303T150000Z\r\n" +
+	"DTEND:20150303T160000Z\r\n" +
+	"STATUS:CANCELLED\r\n" +
+	"END:VEVENT\r\n" +
+	"END:VCALENDAR\r\n"
+
+func TestParseAppointmentsICS(t *testing.T) {
+	ny, _ := LoadLocation("America/New_York")
+	tp := TaskParams{
+		Location:          ny,
+		StartTaskSchedule: Date(2015, 3, 2, 14, 0, 0, 0, UTC),
+		EndTaskSchedule:   Date(2015, 3, 10, 0, 0, 0, 0, UTC),
+	}
+
+	Convey("Recurring events are expanded within the window, honoring EXDATEs and overrides", t, func() {
+		appts, err := tp.parseAppointmentsICS(testAppointmentsICS)
+		So(err, ShouldBeNil)
+
+		expected := []Appointment{
+			{"Standup, daily", Date(2015, 3, 2, 9, 30, 0, 0, ny), Date(2015, 3, 2, 10, 0, 0, 0, ny)},
+			{"Standup, daily", Date(2015, 3, 3, 9, 30, 0, 0, ny), Date(2015, 3, 3, 10, 0, 0, 0, ny)},
+			{"Standup, moved", Date(2015, 3, 5, 16, 0, 0, 0, ny), Date(2015, 3, 5, 16, 30, 0, 0, ny)},
+			{"Team offsite with a long description that is folded onto the next line",
+				Date(2015, 3, 6, 0, 0, 0, 0, ny), Date(2015, 3, 7, 0, 0, 0, 0, ny)},
+			{"Standup, daily", Date(2015, 3, 6, 9, 30, 0, 0, ny), Date(2015, 3, 6, 10, 0, 0, 0, ny)},
+			{"Standup, daily", Date(2015, 3, 9, 9, 30, 0, 0, ny), Date(2015, 3, 9, 10, 0, 0, 0, ny)},
+		}
+		So(len(appts), ShouldEqual, len(expected))
+		for i := range expected {
+			So(appts[i].Title, ShouldEqual, expected[i].Title)
+			So(appts[i].Start, ShouldHappenOnOrBetween, expected[i].Start, expected[i].Start)
+			So(appts[i].End, ShouldHappenOnOrBetween, expected[i].End, expected[i].End)
+		}
+	})
+
+	Convey("Invalid calendars are rejected", t, func() {
+		_, err := tp.parseAppointmentsICS("BEGIN:VEVENT\r\nSUMMARY:No start\r\nEND:VEVENT\r\n")
+		So(err.Error(), ShouldEqual, "appointmentsIcs is invalid: VEVENT without DTSTART: No start")
+		_, err = tp.parseAppointmentsICS("BEGIN:VEVENT\r\nDTSTART:2015-03-02\r\nEND:VEVENT\r\n")
+		So(err, ShouldNotBeNil)
+		_, err = tp.parseAppointmentsICS("BEGIN:VEVENT\r\nDTSTART:20150302T090000Z\r\nDURATION:1H\r\nEND:VEVENT\r\n")
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestAppointmentsICSParam(t *testing.T) {
+	params := map[string]interface{}{
+		"timeZone":         "America/New_York",
+		"weeklyTaskBlocks": [][]map[string]string{{}, {{"start": "9:00", "end": "12:00"}}, {}, {}, {}, {}, {}},
+		"tasks":            []map[string]interface{}{{"title": "Write", "estimatedHours": 1, "reward": 5}},
+		"appointments": []map[string]string{
+			{"title": "Call", "start": "2015-03-02T16:00:00Z", "end": "2015-03-02T17:00:00Z"},
+		},
+		"startTaskSchedule": "2015-03-02T14:00:00Z",
+		"endTaskSchedule":   "2015-03-03T14:00:00Z",
+	}
+	paramsJSON, _ := json.Marshal(params)
+	params["appointmentsIcs"] = testAppointmentsICS
+	paramsWithICS, _ := json.Marshal(params)
+
+	expectedJSON := `[{"title":"Write","start":"2015-03-02T15:00:00Z","end":"2015-03-02T16:00:00Z","finish":true}]`
+
+	compactJSON := func(b []byte) string {
+		var out bytes.Buffer
+		So(json.Compact(&out, b), ShouldBeNil)
+		return out.String()
+	}
+
+	Convey("Appointments from appointmentsIcs are added to the JSON ones", t, func() {
+		// The 9:30 standup and 11:00 call leave only 10:00-11:00 free
+		out, err := parseAndComputeSchedule(paramsWithICS)
+		So(err, ShouldBeNil)
+		So(compactJSON(out), ShouldEqual, expectedJSON)
+	})
+
+	Convey("The calendar can be uploaded as a multipart form file", t, func() {
+		var body bytes.Buffer
+		form := multipart.NewWriter(&body)
+		form.WriteField("params", string(paramsJSON))
+		file, _ := form.CreateFormFile("appointments", "calendar.ics")
+		file.Write([]byte(testAppointmentsICS))
+		form.Close()
+
+		r := httptest.NewRequest("POST", "/", &body)
+		r.Header.Set("Content-Type", form.FormDataContentType())
+		w := httptest.NewRecorder()
+		computeScheduleHandler(w, r)
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(compactJSON(w.Body.Bytes()), ShouldEqual, expectedJSON)
+	})
+}
@@ -0,0 +1,310 @@
+package main
+
+import (
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	. "time"
+)
+
+// Jobs compute schedules in the background, for requests that take too long to wait on. POST /jobs
+// queues a request, GET /jobs/{id} polls it for its result and DELETE /jobs/{id} cancels it. A
+// fixed number of workers run the jobs, each of which times out after the job timeout.
+const (
+	JobQueued   = "queued"
+	JobRunning  = "running"
+	JobDone     = "done"
+	JobFailed   = "failed"
+	JobCanceled = "canceled"
+	JobTimedOut = "timedOut"
+)
+
+const (
+	DefaultJobWorkers        = 2
+	DefaultJobTimeoutSeconds = 300
+	// Jobs waiting for a worker before new ones are turned away
+	maxQueuedJobs = 100
+	// How long a finished job is kept for its result to be fetched
+	jobRetention = Hour
+)
+
+var errJobQueueFull = errors.New("Too many jobs are queued, try again later")
+
+// The state of a job as returned by the jobs API. The result is the schedule as the / endpoint would
+// return it, and the error is the error response it would give. Optimal is false for a result the
+// solver stopped on with the best schedule it had found.
+type JobStatus struct {
+	ID       string                 `json:"id"`
+	State    string                 `json:"state"`
+	Created  Time                   `json:"created"`
+	Started  *Time                  `json:"started,omitempty"`
+	Finished *Time                  `json:"finished,omitempty"`
+	Result   json.RawMessage        `json:"result,omitempty"`
+	Optimal  *bool                  `json:"optimal,omitempty"`
+	Error    map[string]interface{} `json:"error,omitempty"`
+}
+
+type job struct {
+	status   JobStatus
+	tp       *TaskParams
+	canceled atomic.Bool
+}
+
+type jobQueue struct {
+	mu      sync.Mutex
+	jobs    map[string]*job
+	pending chan *job
+	timeout Duration
+}
+
+func newJobQueue(workers int, timeout Duration) *jobQueue {
+	q := &jobQueue{
+		jobs:    make(map[string]*job),
+		pending: make(chan *job, maxQueuedJobs),
+		timeout: timeout,
+	}
+	for i := 0; i < workers; i++ {
+		go q.work()
+	}
+	return q
+}
+
+// The jobs queue for the server, configured by the JOB_WORKERS and JOB_TIMEOUT_SECONDS environment
+// variables
+func newJobQueueFromEnv() *jobQueue {
+	workers := envInt("JOB_WORKERS", DefaultJobWorkers)
+	timeout := envInt("JOB_TIMEOUT_SECONDS", DefaultJobTimeoutSeconds)
+	return newJobQueue(workers, Duration(timeout)*Second)
+}
+
+func envInt(name string, defaultValue int) int {
+	if value, err := strconv.Atoi(os.Getenv(name)); err == nil && value > 0 {
+		return value
+	}
+	return defaultValue
+}
+
+func newJobID() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(b), nil
+}
+
+func (q *jobQueue) submit(tp *TaskParams) (JobStatus, error) {
+	id, err := newJobID()
+	if err != nil {
+		return JobStatus{}, err
+	}
+	j := &job{status: JobStatus{ID: id, State: JobQueued, Created: Now().UTC()}, tp: tp}
+
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.removeExpired()
+	select {
+	case q.pending <- j:
+	default:
+		return JobStatus{}, errJobQueueFull
+	}
+	q.jobs[id] = j
+	return j.status, nil
+}
+
+// Forget the jobs that finished longer than jobRetention ago, which is done whenever the jobs are
+// looked up. Must be called with q.mu held.
+func (q *jobQueue) removeExpired() {
+	for id, j := range q.jobs {
+		if j.status.Finished != nil && Since(*j.status.Finished) > jobRetention {
+			delete(q.jobs, id)
+		}
+	}
+}
+
+func (q *jobQueue) get(id string) (JobStatus, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.removeExpired()
+	j, ok := q.jobs[id]
+	if !ok {
+		return JobStatus{}, false
+	}
+	return j.status, true
+}
+
+// A queued job is canceled right away, while a running one stops once the solver next checks for it
+func (q *jobQueue) cancel(id string) (JobStatus, bool) {
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	q.removeExpired()
+	j, ok := q.jobs[id]
+	if !ok {
+		return JobStatus{}, false
+	}
+	switch j.status.State {
+	case JobQueued:
+		q.finish(j, JobCanceled, nil, nil)
+	case JobRunning:
+		j.canceled.Store(true)
+	}
+	return j.status, true
+}
+
+func (q *jobQueue) work() {
+	for j := range q.pending {
+		q.run(j)
+	}
+}
+
+func (q *jobQueue) run(j *job) {
+	q.mu.Lock()
+	if j.status.State != JobQueued {
+		// Canceled while it was queued
+		q.mu.Unlock()
+		return
+	}
+	started := Now().UTC()
+	j.status.State, j.status.Started = JobRunning, &started
+	tp := j.tp
+	q.mu.Unlock()
+	defer func() {
+		// A panic computing the schedule fails the job rather than taking down the server
+		if r := recover(); r != nil {
+			q.mu.Lock()
+			defer q.mu.Unlock()
+			q.finish(j, JobFailed, nil, fmt.Errorf("Failed to compute the schedule: %v", r))
+		}
+	}()
+
+	tp.abort = j.canceled.Load
+	if q.timeout > 0 {
+		tp.solveDeadline = started.Add(q.timeout)
+	}
+	var result []byte
+	err := tp.calcSchedule()
+	if err == nil {
+		result, err = tp.taskScheduleJSON()
+	}
+
+	state := jobState(err)
+	if state == JobDone && tp.suboptimal && j.canceled.Load() {
+		// Stopped by the cancel with the best schedule found, which is kept as the result
+		state = JobCanceled
+	}
+	q.mu.Lock()
+	defer q.mu.Unlock()
+	if result != nil {
+		optimal := !tp.suboptimal
+		j.status.Optimal = &optimal
+	}
+	q.finish(j, state, result, err)
+}
+
+// Must be called with q.mu held
+func (q *jobQueue) finish(j *job, state string, result []byte, err error) {
+	finished := Now().UTC()
+	j.status.State, j.status.Finished = state, &finished
+	j.status.Result = result
+	if err != nil {
+		j.status.Error = errResponse(err)
+	}
+	j.tp = nil
+}
+
+// The state a job ends in after computing its schedule, with lp_solve's TIMEOUT and USERABORT
+// solution types for the job timeout and cancellation
+func jobState(err error) string {
+	if err == nil {
+		return JobDone
+	}
+	if solveErr, ok := err.(*SolveError); ok {
+		switch solveErr.Solution {
+		case TIMEOUT:
+			return JobTimedOut
+		case USERABORT:
+			return JobCanceled
+		}
+	}
+	return JobFailed
+}
+
+// POST /jobs
+func (q *jobQueue) jobsHandler(w http.ResponseWriter, r *http.Request) {
+	// Allow CORS requests
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "content-type")
+
+	if r.Method != "POST" {
+		w.Write([]byte("OK"))
+		return
+	}
+
+	body, err := requestParamsJSON(r)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	// Requests that can't be scheduled at all are rejected rather than queued
+	var tp TaskParams
+	if err := parseTaskParams(body, &tp); err != nil {
+		writeJobJSON(w, http.StatusBadRequest, errResponse(err))
+		return
+	}
+	status, err := q.submit(&tp)
+	if err == errJobQueueFull {
+		writeJobJSON(w, http.StatusServiceUnavailable, errResponse(err))
+		return
+	} else if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Location", "/jobs/"+status.ID)
+	writeJobJSON(w, http.StatusAccepted, status)
+}
+
+// GET and DELETE /jobs/{id}
+func (q *jobQueue) jobHandler(w http.ResponseWriter, r *http.Request) {
+	// Allow CORS requests
+	w.Header().Set("Access-Control-Allow-Origin", "*")
+	w.Header().Set("Access-Control-Allow-Headers", "content-type")
+	w.Header().Set("Access-Control-Allow-Methods", "GET, DELETE")
+
+	id := strings.TrimPrefix(r.URL.Path, "/jobs/")
+	var status JobStatus
+	var ok bool
+	switch r.Method {
+	case "GET":
+		status, ok = q.get(id)
+	case "DELETE":
+		status, ok = q.cancel(id)
+	case "OPTIONS":
+		return
+	default:
+		http.Error(w, "Method not allowed", http.StatusMethodNotAllowed)
+		return
+	}
+	if !ok {
+		writeJobJSON(w, http.StatusNotFound, map[string]interface{}{"err": "No job with id: " + id})
+		return
+	}
+	writeJobJSON(w, http.StatusOK, status)
+}
+
+func writeJobJSON(w http.ResponseWriter, status int, resp interface{}) {
+	respJSON, err := json.Marshal(resp)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	w.Write(respJSON)
+}
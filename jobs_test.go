@@ -0,0 +1,185 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	. "time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestJobs(t *testing.T) {
+	in := `{
+		"timeZone": "America/New_York",
+		"weeklyTaskBlocks": [
+			[],
+			[{"start": "9:00", "end": "13:00"}],
+			[{"start": "9:00", "end": "13:00"}],
+			[],
+			[],
+			[],
+			[]
+		],
+		"tasks": [
+			{"title": "Article", "estimatedHours": 4, "reward": 20, "deadline": "2015-03-03T18:00:00Z"},
+			{"title": "Budget", "estimatedHours": 2, "reward": 5}
+		],
+		"startTaskSchedule": "2015-03-02T14:00:00Z",
+		"endTaskSchedule": "2015-03-04T14:00:00Z"
+	}`
+	request := func(q *jobQueue, method, path, body string) (*httptest.ResponseRecorder, map[string]interface{}) {
+		r := httptest.NewRequest(method, path, strings.NewReader(body))
+		w := httptest.NewRecorder()
+		if path == "/jobs" {
+			q.jobsHandler(w, r)
+		} else {
+			q.jobHandler(w, r)
+		}
+		var resp map[string]interface{}
+		So(json.Unmarshal(w.Body.Bytes(), &resp), ShouldBeNil)
+		return w, resp
+	}
+	// Queue a job without workers and run it here, so it can be canceled or timed out first
+	queueJob := func(q *jobQueue, params string) *job {
+		var tp TaskParams
+		So(parseTaskParams([]byte(params), &tp), ShouldBeNil)
+		_, err := q.submit(&tp)
+		So(err, ShouldBeNil)
+		return <-q.pending
+	}
+
+	Convey("A job is queued, run by a worker and polled for its schedule", t, func() {
+		q := newJobQueue(1, Minute)
+		w, resp := request(q, "POST", "/jobs", in)
+		So(w.Code, ShouldEqual, http.StatusAccepted)
+		So(resp["state"], ShouldEqual, JobQueued)
+		id := resp["id"].(string)
+		So(w.Header().Get("Location"), ShouldEqual, "/jobs/"+id)
+
+		for i := 0; i < 100 && (resp["state"] == JobQueued || resp["state"] == JobRunning); i++ {
+			Sleep(10 * Millisecond)
+			w, resp = request(q, "GET", "/jobs/"+id, "")
+			So(w.Code, ShouldEqual, http.StatusOK)
+		}
+		So(resp["state"], ShouldEqual, JobDone)
+		So(resp["started"], ShouldNotBeNil)
+		So(resp["finished"], ShouldNotBeNil)
+
+		expected, err := parseAndComputeSchedule([]byte(in))
+		So(err, ShouldBeNil)
+		var expectedParsed interface{}
+		So(json.Unmarshal(expected, &expectedParsed), ShouldBeNil)
+		So(resp["result"], ShouldResemble, expectedParsed)
+		So(resp["optimal"], ShouldEqual, true)
+	})
+
+	Convey("Invalid requests are rejected rather than queued", t, func() {
+		q := newJobQueue(0, Minute)
+		w, resp := request(q, "POST", "/jobs", strings.Replace(in, `"estimatedHours": 4`, `"estimatedHours": -4`, 1))
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+		So(resp["errors"], ShouldNotBeEmpty)
+		So(q.jobs, ShouldBeEmpty)
+	})
+
+	Convey("Unknown jobs aren't found", t, func() {
+		q := newJobQueue(0, Minute)
+		w, resp := request(q, "GET", "/jobs/abc", "")
+		So(w.Code, ShouldEqual, http.StatusNotFound)
+		So(resp["err"], ShouldEqual, "No job with id: abc")
+		w, _ = request(q, "DELETE", "/jobs/abc", "")
+		So(w.Code, ShouldEqual, http.StatusNotFound)
+	})
+
+	Convey("A queued job is canceled right away and never run", t, func() {
+		q := newJobQueue(0, Minute)
+		j := queueJob(q, in)
+		w, resp := request(q, "DELETE", "/jobs/"+j.status.ID, "")
+		So(w.Code, ShouldEqual, http.StatusOK)
+		So(resp["state"], ShouldEqual, JobCanceled)
+		q.run(j)
+		status, _ := q.get(j.status.ID)
+		So(status.State, ShouldEqual, JobCanceled)
+		So(status.Started, ShouldBeNil)
+	})
+
+	Convey("Canceling a running job aborts the solver", t, func() {
+		for _, params := range []string{
+			in,
+			strings.Replace(in, `"timeZone"`, `"solver": "simplex", "timeZone"`, 1),
+			strings.Replace(in, `"timeZone"`, `"engine": "flow", "timeZone"`, 1),
+		} {
+			q := newJobQueue(0, Minute)
+			j := queueJob(q, params)
+			j.canceled.Store(true)
+			q.run(j)
+			status, _ := q.get(j.status.ID)
+			So(status.State, ShouldEqual, JobCanceled)
+			So(status.Error["solution"], ShouldEqual, "USERABORT")
+		}
+	})
+
+	Convey("A job that runs out of time times out", t, func() {
+		for _, params := range []string{
+			in,
+			strings.Replace(in, `"timeZone"`, `"diagnose": true, "solver": "simplex", "timeZone"`, 1),
+			strings.Replace(in, `"timeZone"`, `"engine": "flow", "timeZone"`, 1),
+		} {
+			q := newJobQueue(0, Nanosecond)
+			j := queueJob(q, params)
+			q.run(j)
+			status, _ := q.get(j.status.ID)
+			So(status.State, ShouldEqual, JobTimedOut)
+			So(status.Error["solution"], ShouldEqual, "TIMEOUT")
+		}
+	})
+
+	Convey("A job whose solver panics fails instead of crashing the worker", t, func() {
+		solvers["panics"] = func(numCols int) Solver {
+			panic("out of memory")
+		}
+		defer delete(solvers, "panics")
+
+		q := newJobQueue(0, Minute)
+		j := queueJob(q, strings.Replace(in, `"timeZone"`, `"solver": "panics", "timeZone"`, 1))
+		So(func() { q.run(j) }, ShouldNotPanic)
+		status, _ := q.get(j.status.ID)
+		So(status.State, ShouldEqual, JobFailed)
+		So(status.Finished, ShouldNotBeNil)
+		So(status.Error["err"], ShouldEqual, "Failed to compute the schedule: out of memory")
+	})
+
+	Convey("Jobs finished longer ago than they're kept for are forgotten when looked up", t, func() {
+		q := newJobQueue(0, Minute)
+		first, second := queueJob(q, in), queueJob(q, in)
+		expire := func(j *job) {
+			q.cancel(j.status.ID)
+			finished := j.status.Finished.Add(-jobRetention - Second)
+			j.status.Finished = &finished
+		}
+
+		expire(first)
+		w, _ := request(q, "GET", "/jobs/"+first.status.ID, "")
+		So(w.Code, ShouldEqual, http.StatusNotFound)
+		So(len(q.jobs), ShouldEqual, 1)
+
+		expire(second)
+		w, _ = request(q, "DELETE", "/jobs/"+second.status.ID, "")
+		So(w.Code, ShouldEqual, http.StatusNotFound)
+		So(q.jobs, ShouldBeEmpty)
+	})
+
+	Convey("Jobs past the queue limit are turned away", t, func() {
+		q := newJobQueue(0, Minute)
+		var tp TaskParams
+		for i := 0; i < maxQueuedJobs; i++ {
+			_, err := q.submit(&tp)
+			So(err, ShouldBeNil)
+		}
+		w, resp := request(q, "POST", "/jobs", in)
+		So(w.Code, ShouldEqual, http.StatusServiceUnavailable)
+		So(resp["err"], ShouldEqual, errJobQueueFull.Error())
+	})
+}
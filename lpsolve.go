@@ -0,0 +1,33 @@
+//go:build cgo && !purego
+
+package main
+
+import "github.com/draffensperger/schedule/golp"
+
+func init() {
+	solvers[LPSolveSolver] = newLPSolveSolver
+	DefaultSolver = LPSolveSolver
+}
+
+// The lp_solve library through golp, which needs liblpsolve55.so at run time
+type lpSolveSolver struct {
+	*golp.LP
+}
+
+func newLPSolveSolver(numCols int) Solver {
+	lp := golp.NewLP(0, numCols)
+	lp.SetVerboseLevel(golp.IMPORTANT)
+	return lpSolveSolver{lp}
+}
+
+func (s lpSolveSolver) AddConstraintSparse(row []Entry, ct ConstraintType, rightHand float64) error {
+	entries := make([]golp.Entry, len(row))
+	for i, entry := range row {
+		entries[i] = golp.Entry{Col: entry.Col, Val: entry.Val}
+	}
+	return s.LP.AddConstraintSparse(entries, golp.ConstraintType(ct), rightHand)
+}
+
+func (s lpSolveSolver) Solve() SolutionType {
+	return SolutionType(s.LP.Solve())
+}
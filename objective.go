@@ -0,0 +1,74 @@
+package main
+
+import "math"
+
+// Options shaping the objective function. The zero value gives the default objective: a decay of
+// 0.99 per available slot and the task length penalty, with no urgency bonus.
+type ObjectiveParams struct {
+	// How much less a slot is worth than the one before it, expressing the preference to get
+	// things done sooner rather than later. Defaults to DefaultDecayRate.
+	DecayRate float64
+	// Apply the decay per calendar hour from the start of the schedule rather than per available
+	// slot, so slots after a weekend are worth less than those right before it
+	DecayPerHour bool
+	// Whether to multiply a task's reward by the decay rate to the power of its estimated hours,
+	// favoring shorter tasks. Defaults to true.
+	LengthPenalty *bool
+	// Tasks with a deadline within UrgencyWindowHours of the start of the schedule have their
+	// reward increased by up to this fraction, scaling linearly from none for a deadline at the
+	// end of the window to all of it for a deadline at the start
+	UrgencyBonus       float64
+	UrgencyWindowHours float64
+	// How much more a slot in one of a task's preferredBlocks is worth for it, as a fraction of
+	// its value. Defaults to DefaultPreferredBlockBonus.
+	PreferredBlockBonus float64
+	// The objective value lost for each hour of a planned pinned event that is moved
+	StabilityPenalty float64
+}
+
+const DefaultDecayRate = 0.99
+
+// Fill in the defaults for options not given, which validate has already checked
+func (tp *TaskParams) setObjectiveDefaults() {
+	obj := &tp.Objective
+	if obj.DecayRate == 0 {
+		obj.DecayRate = DefaultDecayRate
+	}
+	if obj.LengthPenalty == nil {
+		lengthPenalty := true
+		obj.LengthPenalty = &lengthPenalty
+	}
+	if obj.PreferredBlockBonus == 0 {
+		obj.PreferredBlockBonus = DefaultPreferredBlockBonus
+	}
+}
+
+// The time preference value of each of the TaskHours, starting from 1
+func (tp TaskParams) slotValues() []float64 {
+	values := make([]float64, len(tp.TaskHours))
+	curHourValue := 1.0
+	for hour, t := range tp.TaskHours {
+		if tp.Objective.DecayPerHour {
+			values[hour] = math.Pow(tp.Objective.DecayRate, t.Sub(tp.StartTaskSchedule).Hours())
+		} else {
+			values[hour] = curHourValue
+			curHourValue *= tp.Objective.DecayRate
+		}
+	}
+	return values
+}
+
+// The multiplier for the task reward from the length penalty and the urgency bonus
+func (tp TaskParams) rewardFactor(task Task) float64 {
+	factor := 1.0
+	if tp.Objective.LengthPenalty == nil || *tp.Objective.LengthPenalty {
+		factor *= math.Pow(tp.Objective.DecayRate, task.EstimatedHours)
+	}
+	if tp.Objective.UrgencyBonus > 0 && !task.Deadline.IsZero() {
+		hoursLeft := math.Max(task.Deadline.Sub(tp.StartTaskSchedule).Hours(), 0)
+		if hoursLeft < tp.Objective.UrgencyWindowHours {
+			factor *= 1 + tp.Objective.UrgencyBonus*(1-hoursLeft/tp.Objective.UrgencyWindowHours)
+		}
+	}
+	return factor
+}
@@ -0,0 +1,93 @@
+package main
+
+import (
+	"math"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestObjectiveParams(t *testing.T) {
+	in := `{
+		"timeZone": "America/New_York",
+		OBJECTIVE
+		"weeklyTaskBlocks": [
+			[],
+			[{"start": "10:00", "end": "12:00"}],
+			[{"start": "10:00", "end": "12:00"}],
+			[],
+			[],
+			[],
+			[]
+		],
+		"tasks": [
+			{"title": "Newsletter", "estimatedHours": 1, "reward": 10},
+			{"title": "Admin", "estimatedHours": 2, "reward": 9, "deadline": "2015-03-03T17:00:00Z"}
+		],
+		"startTaskSchedule": "2015-03-02T14:00:00Z",
+		"endTaskSchedule": "2015-03-04T14:00:00Z"
+	}`
+	withObjective := func(objective string) []byte {
+		return []byte(strings.Replace(in, "OBJECTIVE", objective, 1))
+	}
+	coefficients := func(objective string) [][]float64 {
+		explanation, err := explainSchedule(withObjective(objective))
+		So(err, ShouldBeNil)
+		return [][]float64{explanation.Tasks[0].ObjectiveCoefficients, explanation.Tasks[1].ObjectiveCoefficients}
+	}
+	titles := func(objective string) []string {
+		tp, err := computeSchedule(withObjective(objective))
+		So(err, ShouldBeNil)
+		titles := make([]string, 0)
+		for _, event := range tp.TaskEvents {
+			titles = append(titles, event.Title)
+		}
+		return titles
+	}
+
+	Convey("By default each slot decays by 0.99 with the task length penalty", t, func() {
+		c := coefficients("")
+		So(c[0][0], ShouldAlmostEqual, 0.99*10, 0.001)
+		So(c[0][2], ShouldAlmostEqual, 0.99*0.99*0.99*10, 0.001)
+		So(c[1][0], ShouldAlmostEqual, 0.99*0.99*9/2, 0.001)
+		So(titles(""), ShouldResemble, []string{"Newsletter", "Admin", "Admin"})
+	})
+
+	Convey("The decay rate and length penalty can be changed", t, func() {
+		c := coefficients(`"objective": {"decayRate": 0.5, "lengthPenalty": false},`)
+		So(c[0][0], ShouldAlmostEqual, 10, 0.001)
+		So(c[0][1], ShouldAlmostEqual, 5, 0.001)
+		So(c[1][0], ShouldAlmostEqual, 4.5, 0.001)
+	})
+
+	Convey("The decay can be per calendar hour", t, func() {
+		c := coefficients(`"objective": {"decayPerHour": true, "lengthPenalty": false},`)
+		// The slots are at 10:00 and 11:00 on Monday and Tuesday, and the schedule starts at 9:00 Monday
+		So(c[0][0], ShouldAlmostEqual, 10*0.99, 0.0001)
+		So(c[0][1], ShouldAlmostEqual, 10*0.99*0.99, 0.0001)
+		So(c[0][2], ShouldAlmostEqual, 10*math.Pow(0.99, 25), 0.0001)
+	})
+
+	Convey("Tasks with deadlines near the start of the schedule get an urgency bonus", t, func() {
+		// Admin's deadline is 27 hours after the start, so gets 1 + 4 * (1 - 27/54) times its reward
+		urgency := `"objective": {"urgencyBonus": 4, "urgencyWindowHours": 54},`
+		c := coefficients(urgency)
+		So(c[0][0], ShouldAlmostEqual, 0.99*10, 0.001)
+		So(c[1][0], ShouldAlmostEqual, 0.99*0.99*9/2*3, 0.001)
+		So(titles(urgency), ShouldResemble, []string{"Admin", "Newsletter"})
+
+		// Outside the window there's no bonus
+		c = coefficients(`"objective": {"urgencyBonus": 4, "urgencyWindowHours": 12},`)
+		So(c[1][0], ShouldAlmostEqual, 0.99*0.99*9/2, 0.001)
+	})
+
+	Convey("Invalid objective params are rejected", t, func() {
+		_, err := computeSchedule(withObjective(`"objective": {"decayRate": 1.5},`))
+		So(err, ShouldNotBeNil)
+		_, err = computeSchedule(withObjective(`"objective": {"urgencyBonus": -1},`))
+		So(err, ShouldNotBeNil)
+		_, err = computeSchedule(withObjective(`"objective": {"urgencyBonus": 1},`))
+		So(err.Error(), ShouldEqual, "objective.urgencyWindowHours is needed with an objective.urgencyBonus")
+	})
+}
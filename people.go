@@ -0,0 +1,114 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	. "time"
+)
+
+// A member of a team whose tasks are scheduled together. Each person has their own work blocks,
+// appointments and time zone, and the slots of all of them make up the TaskHours.
+type Person struct {
+	Name             string
+	TimeZoneName     string `json:"timeZone"`
+	WeeklyTaskBlocks [][]TimeBlock
+	Appointments     []Appointment
+	location         *Location
+}
+
+// Check the people and resolve the people each task may be assigned to
+func (tp *TaskParams) resolvePeople() error {
+	personNums := make(map[string]int)
+	for personNum := range tp.People {
+		person := &tp.People[personNum]
+		personNums[person.Name] = personNum
+		person.location = tp.Location
+		if person.TimeZoneName != "" {
+			loc, err := LoadLocation(person.TimeZoneName)
+			if err != nil {
+				return fieldError(fmt.Sprintf("people[%d].timeZone", personNum), "is not a known time zone: "+person.TimeZoneName)
+			}
+			person.location = loc
+		}
+	}
+
+	for taskNum := range tp.Tasks {
+		task := &tp.Tasks[taskNum]
+		task.people = nil
+		if len(task.People) > 0 && len(tp.People) == 0 {
+			return fieldError(task.field("people"), "can't be given without the request's people")
+		}
+		for i, name := range task.People {
+			personNum, ok := personNums[name]
+			if !ok {
+				return fieldError(task.field(fmt.Sprintf("people[%d]", i)), "is not one of the people: "+name)
+			}
+			task.people = append(task.people, personNum)
+		}
+	}
+	return nil
+}
+
+// The available slots of every person, sorted by time and then by person
+func (tp *TaskParams) calculatePeopleTaskHours() {
+	type personSlot struct {
+		t         Time
+		personNum int
+	}
+	slots := make([]personSlot, 0)
+	for personNum, person := range tp.People {
+		personParams := *tp
+		personParams.Location = person.location
+		personParams.WeeklyTaskBlocks = person.WeeklyTaskBlocks
+		personParams.Appointments = append(append([]Appointment{}, tp.Appointments...), person.Appointments...)
+		personParams.StartTaskSchedule = tp.StartTaskSchedule.In(person.location)
+		for _, t := range personParams.availableSlots() {
+			slots = append(slots, personSlot{t, personNum})
+		}
+	}
+	sort.SliceStable(slots, func(i, j int) bool { return slots[i].t.Before(slots[j].t) })
+
+	tp.TaskHours = make([]Time, len(slots))
+	tp.slotPeople = make([]int, len(slots))
+	for hour, slot := range slots {
+		tp.TaskHours[hour] = slot.t
+		tp.slotPeople[hour] = slot.personNum
+	}
+}
+
+// The index of the person whose slot it is, or 0 without people
+func (tp TaskParams) slotPerson(hour int) int {
+	if tp.slotPeople == nil {
+		return 0
+	}
+	return tp.slotPeople[hour]
+}
+
+// The time zone of the person whose slot it is, or the request's without people
+func (tp TaskParams) slotLocation(hour int) *Location {
+	if tp.slotPeople == nil {
+		return tp.Location
+	}
+	return tp.People[tp.slotPeople[hour]].location
+}
+
+func (tp TaskParams) slotPersonName(hour int) string {
+	if tp.slotPeople == nil {
+		return ""
+	}
+	return tp.People[tp.slotPeople[hour]].Name
+}
+
+// Restrict the task's allowed slots to those of the people who may do it
+func (tp TaskParams) setPeopleSlots(task *Task) {
+	if len(task.people) == 0 {
+		return
+	}
+	allowed := make([]bool, len(tp.TaskHours))
+	for hour := range tp.TaskHours {
+		for _, personNum := range task.people {
+			allowed[hour] = allowed[hour] || (tp.slotPerson(hour) == personNum && task.allowedSlot(hour))
+		}
+	}
+	task.allowedSlots = allowed
+}
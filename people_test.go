@@ -0,0 +1,128 @@
+package main
+
+import (
+	"encoding/json"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPeople(t *testing.T) {
+	in := `{
+		"timeZone": "America/New_York",
+		"people": [
+			{
+				"name": "Alice",
+				"weeklyTaskBlocks": [[], [{"start": "9:00", "end": "12:00"}], [], [], [], [], []],
+				"appointments": [{"title": "Dentist", "start": "2015-03-02T16:00:00Z", "end": "2015-03-02T17:00:00Z"}]
+			},
+			{
+				"name": "Bob",
+				"timeZone": "America/Chicago",
+				"weeklyTaskBlocks": [[], [{"start": "9:00", "end": "12:00"}], [], [], [], [], []]
+			}
+		],
+		"tasks": [
+			{"title": "Design", "estimatedHours": 2, "reward": 10, "people": ["Alice"], "minChunkHours": 2},
+			{"title": "Build", "estimatedHours": 2, "reward": 8, "people": ["Bob"], "dependsOn": ["Design"]},
+			{"title": "Email", "estimatedHours": 1, "reward": 1}
+		],
+		"startTaskSchedule": "2015-03-02T14:00:00Z",
+		"endTaskSchedule": "2015-03-03T14:00:00Z"
+	}`
+
+	Convey("Each person's slots are used for the tasks they may do", t, func() {
+		var tp TaskParams
+		So(parseTaskParams([]byte(in), &tp), ShouldBeNil)
+		// Alice has 9:00 and 10:00 in New York (14:00 and 15:00 UTC) before her appointment and Bob
+		// has 9:00 to 12:00 in Chicago (15:00 to 18:00 UTC)
+		So(len(tp.TaskHours), ShouldEqual, 5)
+		So(tp.slotPeople, ShouldResemble, []int{0, 0, 1, 1, 1})
+		So(tp.prevSlot(3), ShouldEqual, 2)
+		So(tp.prevSlot(2), ShouldEqual, -1)
+		So(tp.nextSlot(0), ShouldEqual, 1)
+		So(tp.nextSlot(1), ShouldEqual, -1)
+
+		out, err := parseAndComputeSchedule([]byte(in))
+		So(err, ShouldBeNil)
+		var actualParsed, expectedParsed []interface{}
+		So(json.Unmarshal(out, &actualParsed), ShouldBeNil)
+		So(json.Unmarshal([]byte(`[
+			{"title": "Design", "start": "2015-03-02T14:00:00Z", "end": "2015-03-02T16:00:00Z", "finish": true, "assignee": "Alice"},
+			{"title": "Email", "start": "2015-03-02T15:00:00Z", "end": "2015-03-02T16:00:00Z", "finish": true, "assignee": "Bob"},
+			{"title": "Build", "start": "2015-03-02T16:00:00Z", "end": "2015-03-02T18:00:00Z", "finish": true, "assignee": "Bob"}
+		]`), &expectedParsed), ShouldBeNil)
+		So(actualParsed, ShouldResemble, expectedParsed)
+	})
+
+	Convey("The assignee is in the iCalendar and table output", t, func() {
+		tp, err := computeSchedule([]byte(in))
+		So(err, ShouldBeNil)
+		So(string(tp.taskScheduleICS()), ShouldContainSubstring, `DESCRIPTION:finish: true\nassignee: Alice`)
+		So(string(tp.taskScheduleTable()), ShouldContainSubstring, "ASSIGNEE")
+	})
+
+	Convey("The hour caps apply to each person's days in their own time zone", t, func() {
+		// Alice's 9:00 to 19:00 on Monday in New York is 14:00 to 24:00 UTC, and Bob's 9:00 to
+		// 19:00 on Tuesday in Sydney is 22:00 Monday to 8:00 Tuesday UTC, so their slots interleave
+		everyDay := `[[{"start": "9:00", "end": "19:00"}], [{"start": "9:00", "end": "19:00"}],
+			[{"start": "9:00", "end": "19:00"}], [{"start": "9:00", "end": "19:00"}],
+			[{"start": "9:00", "end": "19:00"}], [{"start": "9:00", "end": "19:00"}],
+			[{"start": "9:00", "end": "19:00"}]]`
+		params := `{
+			"timeZone": "America/New_York",
+			"people": [
+				{"name": "Alice", "weeklyTaskBlocks": ` + everyDay + `},
+				{"name": "Bob", "timeZone": "Australia/Sydney", "weeklyTaskBlocks": ` + everyDay + `}
+			],
+			"tasks": [{"title": "Support", "estimatedHours": 20, "reward": 10, "maxHoursPerDay": 8}],
+			"startTaskSchedule": "2015-02-16T14:00:00Z",
+			"endTaskSchedule": "2015-02-17T14:00:00Z"
+		}`
+		tp, err := computeSchedule([]byte(params))
+		So(err, ShouldBeNil)
+		hours := make(map[string]float64)
+		for _, event := range tp.TaskEvents {
+			hours[event.Assignee] += event.End.Sub(event.Start).Hours()
+		}
+		So(hours, ShouldResemble, map[string]float64{"Alice": 8, "Bob": 8})
+	})
+
+	Convey("Allowed blocks are in the time zone of the person whose slot it is", t, func() {
+		params := `{
+			"timeZone": "America/New_York",
+			"people": [
+				{"name": "Bob", "timeZone": "Australia/Sydney",
+					"weeklyTaskBlocks": [[], [], [{"start": "9:00", "end": "19:00"}], [], [], [], []]}
+			],
+			"tasks": [{"title": "Writing", "estimatedHours": 2, "reward": 10, "allowedBlocks": [{"start": "9:00", "end": "12:00"}]}],
+			"startTaskSchedule": "2015-02-16T14:00:00Z",
+			"endTaskSchedule": "2015-02-17T14:00:00Z"
+		}`
+		tp, err := computeSchedule([]byte(params))
+		So(err, ShouldBeNil)
+		// Bob's Tuesday morning in Sydney, which is Monday evening in New York
+		So(len(tp.TaskEvents), ShouldEqual, 1)
+		So(tp.TaskEvents[0].Start.In(tp.People[0].location).Format("Mon 15:04"), ShouldEqual, "Tue 09:00")
+		So(tp.TaskEvents[0].End.In(tp.People[0].location).Format("Mon 15:04"), ShouldEqual, "Tue 11:00")
+	})
+
+	Convey("Invalid people are rejected", t, func() {
+		invalid := []struct{ old, new, message string }{
+			{`"people": ["Alice"]`, `"people": ["Carol"]`, "tasks[0].people[0] is not one of the people: Carol"},
+			{`"name": "Bob"`, `"name": "Alice"`, "people[1].name is the same as another person's: Alice; tasks[1].people[0] is not one of the people: Bob"},
+			{`"name": "Alice",`, ``, "people[0].name is required; tasks[0].people[0] is not one of the people: Alice"},
+			{`[[], [{"start": "9:00", "end": "12:00"}], [], [], [], [], []]`, `[[]]`,
+				"people[0].weeklyTaskBlocks must have 7 days, from Sunday to Saturday"},
+			{`"people": [`, `"weeklyTaskBlocks": [[], [], [], [], [], [], []], "others": [`, "tasks[0].people can't be given without the request's people; " +
+				"tasks[1].people can't be given without the request's people"},
+		}
+		for _, params := range invalid {
+			var tp TaskParams
+			err := parseTaskParams([]byte(strings.Replace(in, params.old, params.new, 1)), &tp)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, params.message)
+		}
+	})
+}
@@ -0,0 +1,154 @@
+package main
+
+import (
+	"fmt"
+	. "time"
+)
+
+// An event from a previous schedule given back when rescheduling. A locked event is kept where it
+// is, a done event's hours are taken off the task's estimate, and a planned event can be moved but
+// moving it costs the objective's stabilityPenalty per hour.
+type PinnedEvent struct {
+	// The title or ID of the task
+	Title    string
+	Start    Time
+	End      Time
+	Assignee string
+	Status   string
+	taskNum  int
+}
+
+const (
+	PinnedLocked  = "locked"
+	PinnedDone    = "done"
+	PinnedPlanned = "planned"
+)
+
+// Match the pinned events to their tasks and take the hours done off the task estimates. This is
+// done before the available slots are worked out, as the time of done events isn't available.
+func (tp *TaskParams) resolvePinnedEvents() error {
+	lookup := tp.taskLookup()
+	for i := range tp.PinnedEvents {
+		pinned := &tp.PinnedEvents[i]
+		field := fmt.Sprintf("pinnedEvents[%d]", i)
+		taskNums := lookup(pinned.Title)
+		if len(taskNums) == 0 {
+			return fieldError(field+".title", "is not the id or title of a task: "+pinned.Title)
+		}
+		if len(taskNums) > 1 {
+			return fieldError(field+".title", "is the title of more than one task, use an id: "+pinned.Title)
+		}
+		pinned.taskNum = taskNums[0]
+		task := &tp.Tasks[pinned.taskNum]
+
+		if pinned.Status == "" {
+			pinned.Status = PinnedPlanned
+		}
+		personNum := -1
+		if pinned.Assignee != "" && len(tp.People) > 0 {
+			for i, person := range tp.People {
+				if person.Name == pinned.Assignee {
+					personNum = i
+				}
+			}
+			if personNum < 0 {
+				return fieldError(field+".assignee", "is not one of the people: "+pinned.Assignee)
+			}
+		}
+
+		switch pinned.Status {
+		case PinnedDone:
+			task.EstimatedHours -= pinned.End.Sub(pinned.Start).Hours()
+			// The time is taken, so no other work can be scheduled in it
+			appt := Appointment{Title: task.Title, Start: pinned.Start, End: pinned.End}
+			if personNum >= 0 {
+				tp.People[personNum].Appointments = append(tp.People[personNum].Appointments, appt)
+			} else {
+				tp.Appointments = append(tp.Appointments, appt)
+			}
+		case PinnedLocked:
+			// The part of a locked event before the schedule starts has been done
+			if pinned.Start.Before(tp.StartTaskSchedule) {
+				doneEnd := pinned.End
+				if doneEnd.After(tp.StartTaskSchedule) {
+					doneEnd = tp.StartTaskSchedule
+				}
+				task.EstimatedHours -= doneEnd.Sub(pinned.Start).Hours()
+			}
+		}
+		if task.EstimatedHours < 0 {
+			task.EstimatedHours = 0
+		}
+	}
+	return nil
+}
+
+// Find the slots of the locked and planned events, once the TaskHours and task estimates are known
+func (tp *TaskParams) setPinnedSlots() error {
+	for taskNum := range tp.Tasks {
+		tp.Tasks[taskNum].lockedSlots, tp.Tasks[taskNum].plannedSlots = nil, nil
+	}
+	lockedTasks := make(map[int]int)
+
+	for i, pinned := range tp.PinnedEvents {
+		if pinned.Status == PinnedDone {
+			continue
+		}
+		task := &tp.Tasks[pinned.taskNum]
+		start := pinned.Start
+		if start.Before(tp.StartTaskSchedule) {
+			start = tp.StartTaskSchedule
+		}
+		slots := tp.slotsWithin(start, pinned.End, pinned.Assignee)
+
+		if pinned.Status == PinnedPlanned {
+			task.plannedSlots = append(task.plannedSlots, slots...)
+			continue
+		}
+		if start.Before(pinned.End) && len(slots) < int(pinned.End.Sub(start)/tp.slotDuration()) {
+			return fieldError(fmt.Sprintf("pinnedEvents[%d]", i), "is locked at "+
+				pinned.Start.In(tp.Location).Format("Jan 2 15:04")+", which isn't in the available slots")
+		}
+		for _, hour := range slots {
+			if otherNum, ok := lockedTasks[hour]; ok && otherNum != pinned.taskNum {
+				return fieldError(fmt.Sprintf("pinnedEvents[%d]", i), "is locked at the same time as an event of task: "+tp.Tasks[otherNum].Title)
+			}
+			lockedTasks[hour] = pinned.taskNum
+		}
+		task.lockedSlots = append(task.lockedSlots, slots...)
+	}
+
+	for _, task := range tp.Tasks {
+		if len(task.lockedSlots) > task.estimatedSlots {
+			return fieldError(task.field("estimatedHours"), "is less than the hours of the task's locked pinnedEvents")
+		}
+	}
+	return nil
+}
+
+// The slots that fit between start and end, of the assignee if given, and only one at each time
+func (tp TaskParams) slotsWithin(start, end Time, assignee string) []int {
+	slots := make([]int, 0)
+	for hour, t := range tp.TaskHours {
+		if t.Before(start) || t.Add(tp.slotDuration()).After(end) {
+			continue
+		}
+		if assignee != "" && len(tp.People) > 0 && tp.slotPersonName(hour) != assignee {
+			continue
+		}
+		if len(slots) > 0 && tp.TaskHours[slots[len(slots)-1]].Equal(t) {
+			continue
+		}
+		slots = append(slots, hour)
+	}
+	return slots
+}
+
+// Fix the columns of locked events to 1
+func (tp *TaskParams) addLockedBounds() {
+	for taskNum, task := range tp.Tasks {
+		for _, hour := range task.lockedSlots {
+			tp.lp.SetBounds(tp.col(hour, taskNum), 1, 1)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	. "time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestPinnedEvents(t *testing.T) {
+	in := `{
+		"timeZone": "America/New_York",
+		"weeklyTaskBlocks": [
+			[],
+			[{"start": "9:00", "end": "13:00"}],
+			[{"start": "9:00", "end": "13:00"}],
+			[],
+			[],
+			[],
+			[]
+		],
+		"tasks": [
+			{"id": "a", "title": "Article", "estimatedHours": 4, "reward": 20},
+			{"title": "Budget", "estimatedHours": 2, "reward": 5}
+		],
+		"pinnedEvents": [],
+		"startTaskSchedule": "2015-03-02T14:00:00Z",
+		"endTaskSchedule": "2015-03-04T14:00:00Z"
+	}`
+	ny, _ := LoadLocation("America/New_York")
+	withPinned := func(pinned string) string {
+		return strings.Replace(in, `"pinnedEvents": []`, `"pinnedEvents": [`+pinned+`]`, 1)
+	}
+	eventTimes := func(tp *TaskParams) []string {
+		events := make([]string, 0)
+		for _, event := range tp.TaskEvents {
+			events = append(events, event.Title+" "+event.Start.In(ny).Format("Mon 15:04")+"-"+event.End.In(ny).Format("15:04"))
+		}
+		return events
+	}
+
+	Convey("Without pinned events the more rewarding task goes first", t, func() {
+		tp, err := computeSchedule([]byte(in))
+		So(err, ShouldBeNil)
+		So(eventTimes(tp), ShouldResemble, []string{"Article Mon 09:00-13:00", "Budget Tue 09:00-11:00"})
+	})
+
+	Convey("Locked events stay where they are", t, func() {
+		tp, err := computeSchedule([]byte(withPinned(
+			`{"title": "Budget", "start": "2015-03-02T14:00:00Z", "end": "2015-03-02T15:00:00Z", "status": "locked"}`)))
+		So(err, ShouldBeNil)
+		So(eventTimes(tp), ShouldResemble, []string{
+			"Budget Mon 09:00-10:00", "Article Mon 10:00-13:00", "Article Tue 09:00-10:00", "Budget Tue 10:00-11:00"})
+	})
+
+	Convey("The part of a locked event before the schedule start counts as done", t, func() {
+		var tp TaskParams
+		So(parseTaskParams([]byte(withPinned(
+			`{"title": "a", "start": "2015-03-02T13:00:00Z", "end": "2015-03-02T15:00:00Z", "status": "locked"}`)), &tp), ShouldBeNil)
+		So(tp.Tasks[0].EstimatedHours, ShouldEqual, 3)
+		So(tp.Tasks[0].lockedSlots, ShouldResemble, []int{0})
+	})
+
+	Convey("Done events are taken off the estimate", t, func() {
+		tp, err := computeSchedule([]byte(withPinned(
+			`{"title": "Article", "start": "2015-02-27T14:00:00Z", "end": "2015-02-27T17:00:00Z", "status": "done"}`)))
+		So(err, ShouldBeNil)
+		So(eventTimes(tp), ShouldResemble, []string{"Article Mon 09:00-10:00", "Budget Mon 10:00-12:00"})
+	})
+
+	Convey("Planned events are kept in place with a stability penalty", t, func() {
+		params := withPinned(`{"title": "Budget", "start": "2015-03-02T16:00:00Z", "end": "2015-03-02T18:00:00Z"}`)
+		tp, err := computeSchedule([]byte(params))
+		So(err, ShouldBeNil)
+		So(eventTimes(tp), ShouldResemble, []string{"Article Mon 09:00-13:00", "Budget Tue 09:00-11:00"})
+
+		params = strings.Replace(params, `"tasks"`, `"objective": {"stabilityPenalty": 10}, "tasks"`, 1)
+		tp, err = computeSchedule([]byte(params))
+		So(err, ShouldBeNil)
+		So(eventTimes(tp), ShouldResemble, []string{
+			"Article Mon 09:00-11:00", "Budget Mon 11:00-13:00", "Article Tue 09:00-11:00"})
+	})
+
+	Convey("Invalid pinned events are rejected", t, func() {
+		invalid := []struct{ pinned, message string }{
+			{`{"title": "Bugdet", "start": "2015-03-02T14:00:00Z", "end": "2015-03-02T15:00:00Z"}`,
+				"pinnedEvents[0].title is not the id or title of a task: Bugdet"},
+			{`{"title": "Budget", "start": "2015-03-02T14:00:00Z", "end": "2015-03-02T15:00:00Z", "status": "started"}`,
+				`pinnedEvents[0].status must be "locked", "done" or "planned"`},
+			{`{"title": "Budget", "start": "2015-03-02T15:00:00Z", "end": "2015-03-02T14:00:00Z"}`,
+				"pinnedEvents[0].end must be after start"},
+			{`{"title": "Budget", "start": "2015-03-02T20:00:00Z", "end": "2015-03-02T21:00:00Z", "status": "locked"}`,
+				"pinnedEvents[0] is locked at Mar 2 15:00, which isn't in the available slots"},
+			{`{"title": "Budget", "start": "2015-03-02T14:00:00Z", "end": "2015-03-02T15:00:00Z", "status": "locked"},
+				{"title": "Article", "start": "2015-03-02T14:00:00Z", "end": "2015-03-02T15:00:00Z", "status": "locked"}`,
+				"pinnedEvents[1] is locked at the same time as an event of task: Budget"},
+			{`{"title": "Budget", "start": "2015-03-02T14:00:00Z", "end": "2015-03-02T17:00:00Z", "status": "locked"}`,
+				"tasks[1].estimatedHours is less than the hours of the task's locked pinnedEvents"},
+		}
+		for _, params := range invalid {
+			_, err := computeSchedule([]byte(withPinned(params.pinned)))
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, params.message)
+		}
+	})
+}
@@ -0,0 +1,122 @@
+package main
+
+import (
+	"errors"
+	"strings"
+	. "time"
+)
+
+// How a recurring task repeats. Either Freq (with Interval, ByDay and ByMonthDay) or an RRule such
+// as "FREQ=MONTHLY;BYDAY=-1FR" is given. Each occurrence becomes an instance of the task with its
+// EstimatedHours and Reward, which can't be started before the occurrence and is due DeadlineHours
+// after it.
+type Recurrence struct {
+	Freq       string
+	Interval   int
+	ByDay      []string
+	ByMonthDay []int
+	RRule      string
+	// The first occurrence, whose time of day the others also have. Defaults to midnight on the
+	// day DeadlineHours before the start of the schedule, so instances still due are included.
+	Start         Time
+	DeadlineHours float64
+}
+
+// Replace recurring tasks with their instances that fall within the schedule
+func (tp *TaskParams) expandRecurringTasks() error {
+	tasks := make([]Task, 0, len(tp.Tasks))
+	for _, task := range tp.Tasks {
+		if task.Recurrence == nil {
+			tasks = append(tasks, task)
+			continue
+		}
+		instances, err := tp.recurringTaskInstances(task)
+		if err != nil {
+			return fieldError(task.field("recurrence"), "is invalid: "+err.Error())
+		}
+		tasks = append(tasks, instances...)
+	}
+	tp.Tasks = tasks
+	return nil
+}
+
+func (tp TaskParams) recurringTaskInstances(task Task) ([]Task, error) {
+	recurrence := task.Recurrence
+	deadlineAfter := Duration(recurrence.DeadlineHours * float64(Hour))
+	start := recurrence.Start.In(tp.Location)
+	if recurrence.Start.IsZero() {
+		year, month, day := tp.StartTaskSchedule.Add(-deadlineAfter).In(tp.Location).Date()
+		start = Date(year, month, day, 0, 0, 0, 0, tp.Location)
+	}
+	rule, err := recurrence.rule(tp.Location)
+	if err != nil {
+		return nil, err
+	}
+
+	instances := make([]Task, 0)
+	rule.each(start, func(occurrence Time) bool {
+		if !occurrence.Before(tp.EndTaskSchedule) {
+			return false
+		}
+		instance := task
+		instance.Recurrence = nil
+		instance.Title = task.Title + " (" + occurrence.Format("Jan 2") + ")"
+		if task.ID != "" {
+			instance.ID = task.ID + "@" + occurrence.Format("2006-01-02")
+		}
+		if occurrence.After(task.StartOnOrAfter) {
+			instance.StartOnOrAfter = occurrence
+		}
+		if recurrence.DeadlineHours > 0 {
+			instance.Deadline = occurrence.Add(deadlineAfter)
+			// A deadline past the end of the schedule would force the instance to be done within it
+			if instance.Deadline.After(tp.EndTaskSchedule) {
+				instance.Deadline = Time{}
+			}
+		}
+
+		// Occurrences before the schedule are only still to do if they are due within it
+		if !occurrence.Before(tp.StartTaskSchedule) || instance.Deadline.After(tp.StartTaskSchedule) {
+			instances = append(instances, instance)
+		}
+		return true
+	})
+	return instances, nil
+}
+
+func (recurrence Recurrence) rule(loc *Location) (RRule, error) {
+	if recurrence.RRule != "" {
+		if recurrence.Freq != "" {
+			return RRule{}, errors.New("give either freq or rrule, not both")
+		}
+		return parseRRule(recurrence.RRule, loc)
+	}
+
+	rule := RRule{Freq: strings.ToUpper(recurrence.Freq), Interval: recurrence.Interval, ByMonthDay: recurrence.ByMonthDay}
+	if rule.Freq != Daily && rule.Freq != Weekly && rule.Freq != Monthly {
+		return rule, errors.New("freq must be daily, weekly or monthly")
+	}
+	if rule.Interval == 0 {
+		rule.Interval = 1
+	} else if rule.Interval < 0 {
+		return rule, errors.New("interval must be positive")
+	}
+	for _, day := range recurrence.ByDay {
+		// Allow weekday names like "monday" or "Mon" as well as MO
+		if weekday, err := parseWeekday(day); err == nil {
+			rule.ByDay = append(rule.ByDay, WeekdayNum{Day: weekday})
+			continue
+		}
+		weekdayNum, err := parseWeekdayNum(day)
+		if err != nil {
+			return rule, err
+		}
+		rule.ByDay = append(rule.ByDay, weekdayNum)
+	}
+	for _, monthDay := range rule.ByMonthDay {
+		if monthDay == 0 || monthDay > 31 || monthDay < -31 {
+			return rule, errors.New("byMonthDay must be between 1 and 31, or -31 and -1 from the end of the month")
+		}
+	}
+	return rule, nil
+}
@@ -0,0 +1,102 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	. "time"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestRecurringTasks(t *testing.T) {
+	in := `{
+		"timeZone": "America/New_York",
+		"weeklyTaskBlocks": [
+			[],
+			[{"start": "10:00", "end": "12:00"}],
+			[{"start": "10:00", "end": "12:00"}],
+			[{"start": "10:00", "end": "12:00"}],
+			[{"start": "10:00", "end": "12:00"}],
+			[{"start": "10:00", "end": "12:00"}],
+			[]
+		],
+		"tasks": [
+			{"title": "Weekly report", "estimatedHours": 2, "reward": 8,
+				"recurrence": {"freq": "weekly", "byDay": ["Monday"], "deadlineHours": 96}},
+			{"title": "Invoicing", "estimatedHours": 1, "reward": 5,
+				"recurrence": {"rrule": "FREQ=MONTHLY;BYMONTHDAY=1", "deadlineHours": 240}},
+			{"id": "inbox", "title": "Inbox zero", "estimatedHours": 0.5, "reward": 1,
+				"recurrence": {"freq": "daily", "byDay": ["MO", "TU", "WE", "TH", "FR"], "start": "2015-03-02T16:00:00-05:00"}},
+			{"title": "Project", "estimatedHours": 3, "reward": 6, "dependsOn": ["Weekly report (Mar 2)"]}
+		],
+		"startTaskSchedule": "2015-03-02T14:00:00Z",
+		"endTaskSchedule": "2015-03-14T04:00:00Z"
+	}`
+	ny, _ := LoadLocation("America/New_York")
+
+	Convey("Recurring tasks are expanded into instances within the schedule", t, func() {
+		var tp TaskParams
+		err := parseTaskParams([]byte(in), &tp)
+		So(err, ShouldBeNil)
+
+		titles := make([]string, 0)
+		for _, task := range tp.Tasks {
+			titles = append(titles, task.Title)
+		}
+		So(titles, ShouldResemble, []string{
+			"Weekly report (Mar 2)", "Weekly report (Mar 9)", "Invoicing (Mar 1)",
+			"Inbox zero (Mar 2)", "Inbox zero (Mar 3)", "Inbox zero (Mar 4)", "Inbox zero (Mar 5)", "Inbox zero (Mar 6)",
+			"Inbox zero (Mar 9)", "Inbox zero (Mar 10)", "Inbox zero (Mar 11)", "Inbox zero (Mar 12)", "Inbox zero (Mar 13)",
+			"Project",
+		})
+
+		report := tp.Tasks[1]
+		So(report.EstimatedHours, ShouldEqual, 2)
+		So(report.Reward, ShouldEqual, 8)
+		So(report.StartOnOrAfter.Equal(Date(2015, 3, 9, 0, 0, 0, 0, ny)), ShouldBeTrue)
+		So(report.Deadline.Equal(Date(2015, 3, 13, 0, 0, 0, 0, ny)), ShouldBeTrue)
+		So(report.Recurrence, ShouldBeNil)
+
+		// Invoicing for March was due within the schedule, so it's still to do. It's due 240 hours
+		// later, which is 1am with the change to daylight time.
+		invoicing := tp.Tasks[2]
+		So(invoicing.Deadline.Equal(Date(2015, 3, 11, 1, 0, 0, 0, ny)), ShouldBeTrue)
+		So(invoicing.StartOnOrAfterHourIndex, ShouldEqual, 0)
+
+		inbox := tp.Tasks[3]
+		So(inbox.ID, ShouldEqual, "inbox@2015-03-02")
+		So(inbox.StartOnOrAfter.Equal(Date(2015, 3, 2, 16, 0, 0, 0, ny)), ShouldBeTrue)
+		So(inbox.Deadline.IsZero(), ShouldBeTrue)
+
+		So(tp.Tasks[13].dependsOn, ShouldResemble, []int{0})
+	})
+
+	Convey("Instances are scheduled after their occurrence and by their deadline", t, func() {
+		tp, err := computeSchedule([]byte(in))
+		So(err, ShouldBeNil)
+		for _, event := range tp.TaskEvents {
+			So(event.Start.Before(event.Task.StartOnOrAfter), ShouldBeFalse)
+			if !event.Task.Deadline.IsZero() {
+				So(event.End.After(event.Task.Deadline), ShouldBeFalse)
+			}
+		}
+	})
+
+	Convey("Invalid recurrences are rejected", t, func() {
+		invalid := map[string]string{
+			`{"freq": "yearly"}`:                         "recurrence is invalid: freq must be daily, weekly or monthly",
+			`{"freq": "weekly", "byDay": ["XX"]}`:        "recurrence is invalid: invalid RRULE BYDAY: XX",
+			`{"freq": "weekly", "rrule": "FREQ=WEEKLY"}`: "recurrence is invalid: give either freq or rrule, not both",
+			`{"rrule": "FREQ=WEEKLY;BYSETPOS=1"}`:        "recurrence is invalid: unsupported RRULE part: BYSETPOS",
+			`{"freq": "daily", "deadlineHours": -1}`:     "recurrence.deadlineHours can't be negative",
+			`{"freq": "monthly", "byMonthDay": [0]}`:     "recurrence is invalid: byMonthDay must be between 1 and 31, or -31 and -1 from the end of the month",
+		}
+		for recurrence, message := range invalid {
+			var tp TaskParams
+			params := strings.Replace(in, `{"freq": "weekly", "byDay": ["Monday"], "deadlineHours": 96}`, recurrence, 1)
+			err := parseTaskParams([]byte(params), &tp)
+			So(err, ShouldNotBeNil)
+			So(err.Error(), ShouldEqual, "tasks[0]."+message)
+		}
+	})
+}
@@ -0,0 +1,113 @@
+package main
+
+// The response given for a request with "detailed": true, which has the scheduled task events along
+// with details of how the schedule turned out.
+type ScheduleResponse struct {
+	Events      []TaskEvent       `json:"events"`
+	Unscheduled []UnscheduledTask `json:"unscheduled"`
+	// The value of the objective function for the schedule, which is the sum of the task weighted values
+	Objective float64     `json:"objective"`
+	Tasks     []TaskScore `json:"tasks"`
+	// False when the solver stopped with the best schedule it had found, e.g. for solverTimeoutSeconds
+	Optimal bool `json:"optimal"`
+}
+
+// A task which couldn't have all its estimated hours scheduled
+type UnscheduledTask struct {
+	Title          string  `json:"title"`
+	HoursRequested float64 `json:"hoursRequested"`
+	HoursScheduled float64 `json:"hoursScheduled"`
+	Reason         string  `json:"reason"`
+}
+
+// How much a task contributed to the schedule
+type TaskScore struct {
+	Title          string  `json:"title"`
+	HoursScheduled float64 `json:"hoursScheduled"`
+	// The share of the task reward for the hours scheduled
+	RewardCaptured float64 `json:"rewardCaptured"`
+	// The task's part of the objective value, i.e. the reward for its hours with the time preference
+	// decay and length penalty applied, less any late penalty
+	WeightedValue float64 `json:"weightedValue"`
+}
+
+// Reasons a task wasn't fully scheduled
+const (
+	NoSlots               = "noSlots"
+	StartAfterEnd         = "startAfterEnd"
+	NoAllowedSlots        = "noAllowedSlots"
+	DependencyUnscheduled = "dependencyUnscheduled"
+	HorizonTooShort       = "horizonTooShort"
+)
+
+func (tp *TaskParams) scheduleResponse() ScheduleResponse {
+	return ScheduleResponse{
+		Events:      tp.TaskEvents,
+		Unscheduled: tp.Unscheduled,
+		Objective:   tp.ObjectiveValue,
+		Tasks:       tp.TaskScores,
+		Optimal:     !tp.suboptimal,
+	}
+}
+
+func (tp TaskParams) taskScores() []TaskScore {
+	scores := make([]TaskScore, len(tp.Tasks))
+	for taskNum, task := range tp.Tasks {
+		scores[taskNum] = TaskScore{
+			Title:          task.Title,
+			HoursScheduled: float64(task.slotsScheduled) * tp.slotDuration().Hours(),
+		}
+		if task.estimatedSlots > 0 {
+			scores[taskNum].RewardCaptured = task.Reward * float64(task.slotsScheduled) / float64(task.estimatedSlots)
+		}
+		if task.softDeadline && tp.objective != nil {
+			scores[taskNum].WeightedValue = float64(task.lateSlots) * tp.objective[task.lateCol]
+		}
+	}
+	for hour, task := range tp.TaskSchedule {
+		if task == nil {
+			continue
+		}
+		for taskNum := range tp.Tasks {
+			if task == &tp.Tasks[taskNum] {
+				scores[taskNum].WeightedValue += tp.objective[tp.col(hour, taskNum)]
+			}
+		}
+	}
+	return scores
+}
+
+func (tp TaskParams) unscheduledTasks() []UnscheduledTask {
+	unscheduled := make([]UnscheduledTask, 0)
+	for _, task := range tp.Tasks {
+		if task.slotsScheduled >= task.estimatedSlots {
+			continue
+		}
+		unscheduled = append(unscheduled, UnscheduledTask{
+			Title:          task.Title,
+			HoursRequested: task.EstimatedHours,
+			HoursScheduled: float64(task.slotsScheduled) * tp.slotDuration().Hours(),
+			Reason:         tp.unscheduledReason(task),
+		})
+	}
+	return unscheduled
+}
+
+func (tp TaskParams) unscheduledReason(task Task) string {
+	if len(tp.TaskHours) == 0 {
+		return NoSlots
+	}
+	if task.StartOnOrAfterHourIndex < 0 {
+		return StartAfterEnd
+	}
+	if !tp.hasAllowedSlot(task) {
+		return NoAllowedSlots
+	}
+	for _, prereqNum := range task.dependsOn {
+		prereq := tp.Tasks[prereqNum]
+		if prereq.slotsScheduled < prereq.estimatedSlots {
+			return DependencyUnscheduled
+		}
+	}
+	return HorizonTooShort
+}
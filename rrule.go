@@ -0,0 +1,305 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	. "time"
+)
+
+// The subset of RFC 5545 recurrence rules supported: FREQ of DAILY, WEEKLY, MONTHLY or YEARLY with
+// INTERVAL, COUNT, UNTIL, BYDAY (with ordinals like 1MO or -1FR for monthly and yearly rules),
+// BYMONTHDAY and BYMONTH.
+type RRule struct {
+	Freq       string
+	Interval   int
+	Count      int
+	Until      Time
+	ByDay      []WeekdayNum
+	ByMonthDay []int
+	ByMonth    []Month
+}
+
+// A BYDAY entry, e.g. MO (N = 0, every Monday) or -1FR (N = -1, the last Friday)
+type WeekdayNum struct {
+	N   int
+	Day Weekday
+}
+
+const (
+	Daily   = "DAILY"
+	Weekly  = "WEEKLY"
+	Monthly = "MONTHLY"
+	Yearly  = "YEARLY"
+)
+
+var icsWeekdays = map[string]Weekday{
+	"SU": Sunday, "MO": Monday, "TU": Tuesday, "WE": Wednesday, "TH": Thursday, "FR": Friday, "SA": Saturday,
+}
+
+// Parse a weekday given as an iCalendar code like MO or a name like "monday" or "Mon"
+func parseWeekday(s string) (Weekday, error) {
+	code := strings.ToUpper(strings.TrimSpace(s))
+	if len(code) > 2 {
+		code = code[:2]
+	}
+	day, ok := icsWeekdays[code]
+	if !ok || (len(s) > 2 && !strings.HasPrefix(strings.ToLower(day.String()), strings.ToLower(strings.TrimSpace(s)))) {
+		return day, errors.New("invalid weekday: " + s)
+	}
+	return day, nil
+}
+
+// Upper limit on the periods looked at when expanding a rule, so a rule that never matches a date
+// (e.g. BYMONTHDAY=31 with BYMONTH=2) can't loop forever
+const maxRRulePeriods = 100000
+
+// Parse an RRULE value such as "FREQ=WEEKLY;BYDAY=MO,WE;UNTIL=20150301T000000Z". An UNTIL without
+// a time zone is taken to be in loc.
+func parseRRule(value string, loc *Location) (RRule, error) {
+	rule := RRule{Interval: 1}
+	for _, part := range strings.Split(value, ";") {
+		if part == "" {
+			continue
+		}
+		nameValue := strings.SplitN(part, "=", 2)
+		if len(nameValue) != 2 {
+			return rule, errors.New("invalid RRULE part: " + part)
+		}
+		name, val := strings.ToUpper(nameValue[0]), nameValue[1]
+		var err error
+		switch name {
+		case "FREQ":
+			rule.Freq = strings.ToUpper(val)
+			if rule.Freq != Daily && rule.Freq != Weekly && rule.Freq != Monthly && rule.Freq != Yearly {
+				return rule, errors.New("unsupported RRULE FREQ: " + val)
+			}
+		case "INTERVAL":
+			rule.Interval, err = strconv.Atoi(val)
+			if err == nil && rule.Interval < 1 {
+				err = errors.New("RRULE INTERVAL must be positive")
+			}
+		case "COUNT":
+			rule.Count, err = strconv.Atoi(val)
+		case "UNTIL":
+			rule.Until, _, err = parseICSDateTime(val, loc)
+		case "BYDAY":
+			for _, day := range strings.Split(val, ",") {
+				var weekdayNum WeekdayNum
+				weekdayNum, err = parseWeekdayNum(day)
+				if err != nil {
+					break
+				}
+				rule.ByDay = append(rule.ByDay, weekdayNum)
+			}
+		case "BYMONTHDAY":
+			rule.ByMonthDay, err = parseInts(val, 31)
+		case "BYMONTH":
+			var months []int
+			months, err = parseInts(val, 12)
+			for _, month := range months {
+				if month < 1 {
+					err = errors.New("invalid RRULE BYMONTH: " + val)
+				}
+				rule.ByMonth = append(rule.ByMonth, Month(month))
+			}
+		case "WKST":
+			// Weeks are taken to start on Monday, the default
+		default:
+			return rule, errors.New("unsupported RRULE part: " + name)
+		}
+		if err != nil {
+			return rule, err
+		}
+	}
+	if rule.Freq == "" {
+		return rule, errors.New("RRULE must have a FREQ")
+	}
+	return rule, nil
+}
+
+func parseWeekdayNum(s string) (WeekdayNum, error) {
+	s = strings.ToUpper(strings.TrimSpace(s))
+	if len(s) < 2 {
+		return WeekdayNum{}, errors.New("invalid RRULE BYDAY: " + s)
+	}
+	day, ok := icsWeekdays[s[len(s)-2:]]
+	if !ok {
+		return WeekdayNum{}, errors.New("invalid RRULE BYDAY: " + s)
+	}
+	weekdayNum := WeekdayNum{Day: day}
+	if len(s) > 2 {
+		n, err := strconv.Atoi(s[:len(s)-2])
+		if err != nil || n == 0 {
+			return WeekdayNum{}, errors.New("invalid RRULE BYDAY: " + s)
+		}
+		weekdayNum.N = n
+	}
+	return weekdayNum, nil
+}
+
+// Parse a comma separated list of integers in [-max, max], excluding 0
+func parseInts(s string, max int) ([]int, error) {
+	nums := make([]int, 0)
+	for _, part := range strings.Split(s, ",") {
+		n, err := strconv.Atoi(strings.TrimSpace(part))
+		if err != nil {
+			return nil, err
+		}
+		if n == 0 || n > max || n < -max {
+			return nil, errors.New("value out of range: " + part)
+		}
+		nums = append(nums, n)
+	}
+	return nums, nil
+}
+
+// Call fn with each occurrence of the rule starting at dtstart (which is the first occurrence) in
+// order, until fn returns false or there are no more occurrences. Occurrences keep the wall clock
+// time of dtstart in its location, even across daylight saving time changes.
+func (rule RRule) each(dtstart Time, fn func(Time) bool) {
+	count := 0
+	for period := 0; period < maxRRulePeriods; period++ {
+		for _, occurrence := range rule.periodOccurrences(dtstart, period*rule.Interval) {
+			if occurrence.Before(dtstart) {
+				continue
+			}
+			if !rule.Until.IsZero() && occurrence.After(rule.Until) {
+				return
+			}
+			count++
+			if rule.Count > 0 && count > rule.Count {
+				return
+			}
+			if !fn(occurrence) {
+				return
+			}
+		}
+	}
+}
+
+// The sorted candidate occurrences in the nth day, week, month or year from dtstart
+func (rule RRule) periodOccurrences(dtstart Time, n int) []Time {
+	year, month, day := dtstart.Date()
+	hour, min, sec := dtstart.Clock()
+	loc := dtstart.Location()
+	at := func(year int, month Month, day int) Time {
+		return Date(year, month, day, hour, min, sec, 0, loc)
+	}
+
+	candidates := make([]Time, 0)
+	switch rule.Freq {
+	case Daily:
+		candidates = append(candidates, at(year, month, day+n))
+	case Weekly:
+		// Weeks start on Monday
+		weekStart := day - (int(dtstart.Weekday())+6)%7 + 7*n
+		if len(rule.ByDay) == 0 {
+			candidates = append(candidates, at(year, month, day+7*n))
+		}
+		for _, weekdayNum := range rule.ByDay {
+			candidates = append(candidates, at(year, month, weekStart+(int(weekdayNum.Day)+6)%7))
+		}
+	case Monthly:
+		candidates = rule.monthOccurrences(year, month+Month(n), day, at)
+	case Yearly:
+		months := rule.ByMonth
+		if len(months) == 0 {
+			months = []Month{month}
+		}
+		for _, m := range months {
+			candidates = append(candidates, rule.monthOccurrences(year+n, m, day, at)...)
+		}
+	}
+
+	filtered := make([]Time, 0, len(candidates))
+	for _, candidate := range candidates {
+		if rule.matchesFilters(candidate) {
+			filtered = append(filtered, candidate)
+		}
+	}
+	sort.Slice(filtered, func(i, j int) bool { return filtered[i].Before(filtered[j]) })
+	return filtered
+}
+
+// The candidate days in a month (which may be out of range and is normalized) for a MONTHLY or YEARLY rule
+func (rule RRule) monthOccurrences(year int, month Month, dtstartDay int, at func(int, Month, int) Time) []Time {
+	first := Date(year, month, 1, 0, 0, 0, 0, UTC)
+	year, month = first.Year(), first.Month()
+	daysInMonth := first.AddDate(0, 1, -1).Day()
+
+	candidates := make([]Time, 0)
+	switch {
+	case len(rule.ByMonthDay) > 0:
+		// With BYDAY as well, only the month days falling on one of those weekdays are kept
+		for _, monthDay := range rule.ByMonthDay {
+			if monthDay < 0 {
+				monthDay = daysInMonth + monthDay + 1
+			}
+			if monthDay >= 1 && monthDay <= daysInMonth && rule.onByDay(Date(year, month, monthDay, 0, 0, 0, 0, UTC)) {
+				candidates = append(candidates, at(year, month, monthDay))
+			}
+		}
+	case len(rule.ByDay) > 0:
+		for _, weekdayNum := range rule.ByDay {
+			days := make([]int, 0)
+			for d := 1 + (int(weekdayNum.Day)-int(first.Weekday())+7)%7; d <= daysInMonth; d += 7 {
+				days = append(days, d)
+			}
+			switch {
+			case weekdayNum.N > 0 && weekdayNum.N <= len(days):
+				candidates = append(candidates, at(year, month, days[weekdayNum.N-1]))
+			case weekdayNum.N < 0 && -weekdayNum.N <= len(days):
+				candidates = append(candidates, at(year, month, days[len(days)+weekdayNum.N]))
+			case weekdayNum.N == 0:
+				for _, d := range days {
+					candidates = append(candidates, at(year, month, d))
+				}
+			}
+		}
+	case dtstartDay <= daysInMonth:
+		candidates = append(candidates, at(year, month, dtstartDay))
+	}
+	return candidates
+}
+
+// Whether the day is on one of the BYDAY weekdays, or there are none
+func (rule RRule) onByDay(t Time) bool {
+	if len(rule.ByDay) == 0 {
+		return true
+	}
+	for _, weekdayNum := range rule.ByDay {
+		if t.Weekday() == weekdayNum.Day {
+			return true
+		}
+	}
+	return false
+}
+
+// Apply the BY* parts that limit rather than expand the occurrences for the rule's frequency
+func (rule RRule) matchesFilters(t Time) bool {
+	if len(rule.ByMonth) > 0 && rule.Freq != Yearly {
+		found := false
+		for _, month := range rule.ByMonth {
+			found = found || t.Month() == month
+		}
+		if !found {
+			return false
+		}
+	}
+	if rule.Freq == Daily && !rule.onByDay(t) {
+		return false
+	}
+	if len(rule.ByMonthDay) > 0 && (rule.Freq == Daily || rule.Freq == Weekly) {
+		found := false
+		daysInMonth := Date(t.Year(), t.Month()+1, 0, 0, 0, 0, 0, UTC).Day()
+		for _, monthDay := range rule.ByMonthDay {
+			found = found || t.Day() == monthDay || t.Day() == daysInMonth+monthDay+1
+		}
+		if !found {
+			return false
+		}
+	}
+	return true
+}
@@ -10,15 +10,22 @@ import (
 	"math/rand"
 	"net/http"
 	"os"
+	"reflect"
 	"strconv"
 	"strings"
 	. "time"
-
-	"github.com/draffensperger/golp"
 )
 
 func main() {
+	if len(os.Args) > 1 {
+		os.Exit(runCLI(os.Args[1:], os.Stdin, os.Stdout, os.Stderr))
+	}
+
 	http.HandleFunc("/", computeScheduleHandler)
+	http.HandleFunc("/explain", explainHandler)
+	jobs := newJobQueueFromEnv()
+	http.HandleFunc("/jobs", jobs.jobsHandler)
+	http.HandleFunc("/jobs/", jobs.jobHandler)
 
 	listen := os.Getenv("PORT")
 	if listen == "" {
@@ -34,6 +41,7 @@ func computeScheduleHandler(w http.ResponseWriter, r *http.Request) {
 	// Allow CORS requests
 	w.Header().Set("Access-Control-Allow-Origin", "*")
 	w.Header().Set("Access-Control-Allow-Headers", "content-type")
+	w.Header().Set("Access-Control-Expose-Headers", optimalHeader)
 
 	if r.Method != "POST" {
 		// Return simple OK for a get request to make pinging the service friendly.
@@ -41,31 +49,125 @@ func computeScheduleHandler(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 
-	body, err := ioutil.ReadAll(r.Body)
+	body, err := requestParamsJSON(r)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 
-	scheduleJSON, err := parseAndComputeSchedule(body)
+	tp, err := computeSchedule(body)
 	if err != nil {
-		errJSON, jsonMarshalErr := json.Marshal(map[string]string{"err": err.Error()})
+		errJSON, jsonMarshalErr := json.Marshal(errResponse(err))
 		if jsonMarshalErr != nil {
 			http.Error(w, jsonMarshalErr.Error(), http.StatusInternalServerError)
 			return
 		}
+		w.WriteHeader(errStatus(err))
 		w.Write(errJSON)
 		return
 	}
 
-	_, err = w.Write(scheduleJSON)
+	if tp.suboptimal {
+		// The plain list of events has nowhere to say so, so it's flagged in a header for every format
+		w.Header().Set(optimalHeader, "false")
+	}
+	var schedule []byte
+	if wantsICS(r) {
+		w.Header().Set("Content-Type", "text/calendar; charset=utf-8")
+		schedule = tp.taskScheduleICS()
+	} else {
+		schedule, err = tp.taskScheduleJSON()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+	}
+
+	_, err = w.Write(schedule)
 	if err != nil {
 		http.Error(w, err.Error(), http.StatusInternalServerError)
 		return
 	}
 }
 
+// The params JSON is either the request body or, for a multipart/form-data upload, the "params"
+// field, with an optional "appointments" iCalendar file used as its appointmentsIcs
+func requestParamsJSON(r *http.Request) ([]byte, error) {
+	if !strings.HasPrefix(r.Header.Get("Content-Type"), "multipart/form-data") {
+		return ioutil.ReadAll(r.Body)
+	}
+
+	if err := r.ParseMultipartForm(maxUploadMemory); err != nil {
+		return nil, err
+	}
+	paramsJSON := []byte(r.FormValue("params"))
+	file, _, err := r.FormFile("appointments")
+	if err == http.ErrMissingFile {
+		return paramsJSON, nil
+	} else if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+	ics, err := ioutil.ReadAll(file)
+	if err != nil {
+		return nil, err
+	}
+
+	var params map[string]json.RawMessage
+	if err := json.Unmarshal(paramsJSON, &params); err != nil {
+		return nil, err
+	}
+	if params["appointmentsIcs"], err = json.Marshal(string(ics)); err != nil {
+		return nil, err
+	}
+	return json.Marshal(params)
+}
+
+const maxUploadMemory = 10 << 20
+
+// Set to false on a schedule the solver stopped on before it was known to be optimal
+const optimalHeader = "X-Schedule-Optimal"
+
+func errResponse(err error) map[string]interface{} {
+	if validationErr, ok := err.(*ValidationError); ok {
+		return map[string]interface{}{"errors": validationErr.Errors}
+	}
+	resp := map[string]interface{}{"err": err.Error()}
+	if solveErr, ok := err.(*SolveError); ok {
+		resp["solution"] = solveErr.Solution.String()
+		if solveErr.Conflict != nil {
+			resp["conflict"] = solveErr.Conflict
+		}
+	}
+	return resp
+}
+
+// Invalid requests are a 400 Bad Request, while other errors like an infeasible schedule are given
+// in the response body of a 200 OK
+func errStatus(err error) int {
+	if _, ok := err.(*ValidationError); ok {
+		return http.StatusBadRequest
+	}
+	return http.StatusOK
+}
+
+// The schedule is returned as iCalendar for a ?format=ics query or an Accept: text/calendar header
+func wantsICS(r *http.Request) bool {
+	if format := r.URL.Query().Get("format"); format != "" {
+		return format == "ics"
+	}
+	return strings.Contains(r.Header.Get("Accept"), "text/calendar")
+}
+
 func parseAndComputeSchedule(paramsJSON []byte) ([]byte, error) {
+	tp, err := computeSchedule(paramsJSON)
+	if err != nil {
+		return nil, err
+	}
+	return tp.taskScheduleJSON()
+}
+
+func computeSchedule(paramsJSON []byte) (*TaskParams, error) {
 	var tp TaskParams
 	if err := parseTaskParams(paramsJSON, &tp); err != nil {
 		return nil, err
@@ -73,12 +175,14 @@ func parseAndComputeSchedule(paramsJSON []byte) ([]byte, error) {
 	if err := tp.calcSchedule(); err != nil {
 		return nil, err
 	}
-
-	return tp.taskScheduleJSON()
+	return &tp, nil
 }
 
 func parseTaskParams(paramsJSON []byte, tp *TaskParams) error {
 	if err := json.Unmarshal(paramsJSON, tp); err != nil {
+		return jsonValidationError(err, paramsJSON)
+	}
+	if err := tp.validate(); err != nil {
 		return err
 	}
 
@@ -87,16 +191,64 @@ func parseTaskParams(paramsJSON []byte, tp *TaskParams) error {
 		return err
 	}
 	tp.Location = loc
+	for i := range tp.Tasks {
+		tp.Tasks[i].requestIndex = i
+	}
+	if tp.SlotMinutes == 0 {
+		tp.SlotMinutes = DefaultSlotMinutes
+	}
+	tp.setObjectiveDefaults()
+	if tp.AppointmentsICS != "" {
+		appts, err := tp.parseAppointmentsICS(tp.AppointmentsICS)
+		if err != nil {
+			return err
+		}
+		tp.Appointments = append(tp.Appointments, appts...)
+	}
+	tp.applyTaskProgress()
+	if err := tp.expandRecurringTasks(); err != nil {
+		return err
+	}
+	if err := tp.resolveDependencies(); err != nil {
+		return err
+	}
+	if err := tp.resolvePeople(); err != nil {
+		return err
+	}
+	if err := tp.resolvePinnedEvents(); err != nil {
+		return err
+	}
 	tp.localizeTimes()
 	tp.calculateTaskHours()
 
 	rand.Seed(7777) // Fixed seed so random nudges are deterministic across the same run
 	for i := 0; i < len(tp.Tasks); i++ {
+		tp.Tasks[i].estimatedSlots = tp.hoursAsSlots(tp.Tasks[i].EstimatedHours)
+		if err := tp.setChunkSlots(&tp.Tasks[i]); err != nil {
+			return err
+		}
+		if err := tp.setPeriodSlots(&tp.Tasks[i]); err != nil {
+			return err
+		}
+		if err := tp.setBlockSlots(&tp.Tasks[i]); err != nil {
+			return err
+		}
+		tp.setPeopleSlots(&tp.Tasks[i])
+		tp.Tasks[i].softDeadline = tp.Tasks[i].DeadlineType == SoftDeadline
 		tp.Tasks[i].DeadlineHourIndex = tp.deadlineAsTaskHour(tp.Tasks[i].Deadline)
 		tp.Tasks[i].StartOnOrAfterHourIndex = tp.onOrAfterAsTaskHour(tp.Tasks[i].StartOnOrAfter)
 
-		// Add a small random nudge to each task reward value to break ties and lump similar tasks together
-		tp.Tasks[i].Reward *= 1.0 + (rand.Float64()-0.5)/100000.0
+		tp.Tasks[i].rewardNudge = 1.0
+		if !tp.Binary {
+			// Add a small random nudge to each task reward value to break ties and lump similar tasks together
+			tp.Tasks[i].rewardNudge += (rand.Float64() - 0.5) / 100000.0
+		}
+	}
+	if err := tp.setPinnedSlots(); err != nil {
+		return err
+	}
+	if tp.Engine == FlowEngine {
+		return tp.checkFlowEngine()
 	}
 
 	return nil
@@ -106,27 +258,92 @@ func (tp *TaskParams) calcSchedule() error {
 	if err := tp.deadlineInPastErr(); err != nil {
 		return err
 	}
-
-	if err := tp.setupLP(); err != nil {
-		return err
+	if tp.SolverTimeoutSeconds > 0 {
+		deadline := Now().Add(Duration(tp.SolverTimeoutSeconds) * Second)
+		if tp.solveDeadline.IsZero() || deadline.Before(tp.solveDeadline) {
+			tp.solveDeadline = deadline
+		}
 	}
 
-	tp.lp.SetVerboseLevel(golp.IMPORTANT)
-	//tp.lp.WriteToStdout()
-	ret := tp.lp.Solve()
-	if ret != 0 {
-		return errors.New(`Could not solve linear program`)
+	tp.numCols = 0
+	if len(tp.TaskHours) > 0 {
+		tp.setColumns()
 	}
+	if tp.numCols == 0 {
+		// Nothing can be scheduled, e.g. with no tasks or only tasks starting after the schedule ends,
+		// so there's no need to solve for it
+		tp.TaskSchedule = make([]*Task, len(tp.TaskHours))
+	} else {
+		ret, vars, err := tp.solve()
+		if err != nil {
+			return err
+		}
+		if !ret.hasSchedule() {
+			return tp.solveErr(ret)
+		}
+		tp.suboptimal = ret != OPTIMAL
 
-	if err := tp.interpretTaskSchedule(); err != nil {
-		return err
+		if err := tp.interpretTaskSchedule(vars); err != nil {
+			return err
+		}
+		tp.ObjectiveValue = 0
+		for col, val := range vars {
+			tp.ObjectiveValue += tp.objective[col] * val
+		}
 	}
 	tp.formatTaskEvents()
+	tp.Unscheduled = tp.unscheduledTasks()
+	tp.TaskScores = tp.taskScores()
 
 	return nil
 }
 
+// Solve for the value of each column with the engine of the request
+func (tp *TaskParams) solve() (SolutionType, []float64, error) {
+	if tp.Engine == FlowEngine {
+		return tp.solveFlow()
+	}
+	ret, err := tp.solveLP()
+	if err != nil || !ret.hasSchedule() {
+		return ret, nil, err
+	}
+	return ret, tp.lp.GetVariables(), nil
+}
+
+func (tp *TaskParams) solveLP() (SolutionType, error) {
+	if err := tp.setupLP(); err != nil {
+		return NOTRUN, err
+	}
+	if !tp.solveDeadline.IsZero() {
+		// The deadline is for all the solves of a request, like those to diagnose an infeasible one
+		seconds := int(math.Ceil(Until(tp.solveDeadline).Seconds()))
+		if seconds <= 0 {
+			return TIMEOUT, nil
+		}
+		tp.lp.SetTimeout(seconds)
+	}
+	if tp.abort != nil {
+		tp.lp.SetAbortFunc(tp.abort)
+	}
+
+	return tp.lp.Solve(), nil
+}
+
+// Whether to stop solving, and with which solution type, once a job is canceled or times out
+func (tp TaskParams) solveStopped() (SolutionType, bool) {
+	if tp.abort != nil && tp.abort() {
+		return USERABORT, true
+	}
+	if !tp.solveDeadline.IsZero() && !Now().Before(tp.solveDeadline) {
+		return TIMEOUT, true
+	}
+	return NOTRUN, false
+}
+
 func (tp *TaskParams) taskScheduleJSON() ([]byte, error) {
+	if tp.Detailed {
+		return json.MarshalIndent(tp.scheduleResponse(), "", "  ")
+	}
 	return json.MarshalIndent(tp.TaskEvents, "", "  ")
 }
 
@@ -145,18 +362,138 @@ func (tp *TaskParams) localizeTimes() {
 	}
 }
 
+// Return a function finding the tasks referred to by an ID, or by a title if no task has that ID
+func (tp TaskParams) taskLookup() func(ref string) []int {
+	taskNumsByID := make(map[string][]int)
+	taskNumsByTitle := make(map[string][]int)
+	for i, task := range tp.Tasks {
+		if task.ID != "" {
+			taskNumsByID[task.ID] = append(taskNumsByID[task.ID], i)
+		}
+		taskNumsByTitle[task.Title] = append(taskNumsByTitle[task.Title], i)
+	}
+	return func(ref string) []int {
+		if taskNums, isID := taskNumsByID[ref]; isID {
+			return taskNums
+		}
+		return taskNumsByTitle[ref]
+	}
+}
+
+// Resolve each task's dependsOn references (task ids or titles) to task indices
+func (tp *TaskParams) resolveDependencies() error {
+	lookup := tp.taskLookup()
+	for i := 0; i < len(tp.Tasks); i++ {
+		task := &tp.Tasks[i]
+		task.dependsOn = make([]int, 0, len(task.DependsOn))
+		for j, ref := range task.DependsOn {
+			taskNums := lookup(ref)
+			if len(taskNums) == 0 {
+				return fieldError(task.field(fmt.Sprintf("dependsOn[%d]", j)), "is not the id or title of a task: "+ref)
+			}
+			if len(taskNums) > 1 {
+				return fieldError(task.field(fmt.Sprintf("dependsOn[%d]", j)), "is the title of more than one task, use an id: "+ref)
+			}
+			task.dependsOn = appendUnique(task.dependsOn, taskNums[0])
+		}
+	}
+
+	return tp.dependencyCycleErr()
+}
+
+func appendUnique(nums []int, num int) []int {
+	for _, n := range nums {
+		if n == num {
+			return nums
+		}
+	}
+	return append(nums, num)
+}
+
+// Depth first search through the dependencies, returning an error describing the first cycle found
+func (tp TaskParams) dependencyCycleErr() error {
+	const (
+		unvisited = iota
+		visiting
+		visited
+	)
+	state := make([]int, len(tp.Tasks))
+	path := make([]int, 0)
+
+	var visit func(taskNum int) error
+	visit = func(taskNum int) error {
+		state[taskNum] = visiting
+		path = append(path, taskNum)
+		for _, prereqNum := range tp.Tasks[taskNum].dependsOn {
+			switch state[prereqNum] {
+			case visiting:
+				// List the cycle in the order the tasks would have to be finished
+				titles := []string{tp.Tasks[prereqNum].Title}
+				for i := len(path) - 1; i >= 0; i-- {
+					titles = append(titles, tp.Tasks[path[i]].Title)
+					if path[i] == prereqNum {
+						break
+					}
+				}
+				return fieldError(tp.Tasks[taskNum].field("dependsOn"), "makes a dependency cycle: "+strings.Join(titles, " -> "))
+			case unvisited:
+				if err := visit(prereqNum); err != nil {
+					return err
+				}
+			}
+		}
+		path = path[:len(path)-1]
+		state[taskNum] = visited
+		return nil
+	}
+
+	for taskNum := range tp.Tasks {
+		if state[taskNum] == unvisited {
+			if err := visit(taskNum); err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
+
 type TaskParams struct {
 	TimeZoneName string `json:"timeZone"`
+	Diagnose     bool
+	Binary       bool
+	Detailed     bool
 	*Location
+	// The solver to use, lpsolve or simplex, or DefaultSolver if not given
+	SolverName string `json:"solver"`
+	// Stop solving after this many seconds with the best schedule found by then, or none for zero
+	SolverTimeoutSeconds int
+	// How to schedule the tasks, lp (the default) or flow
+	Engine            string
+	SlotMinutes       int
+	Objective         ObjectiveParams
 	WeeklyTaskBlocks  [][]TimeBlock
+	People            []Person
 	Tasks             []Task
 	Appointments      []Appointment
+	PinnedEvents      []PinnedEvent
+	AppointmentsICS   string `json:"appointmentsIcs"`
 	StartTaskSchedule Time
 	EndTaskSchedule   Time
 	TaskHours         []Time
-	lp                *golp.LP
+	slotPeople        []int
+	lp                Solver
+	numCols           int
+	objective         []float64
 	TaskSchedule      []*Task
 	TaskEvents        []TaskEvent
+	Unscheduled       []UnscheduledTask
+	ObjectiveValue    float64
+	TaskScores        []TaskScore
+	// For jobs, when solving times out and a function that says whether the job was canceled
+	solveDeadline Time
+	abort         func() bool
+	// Whether the solver stopped before finding the optimal schedule
+	suboptimal bool
 }
 
 type Appointment struct {
@@ -166,22 +503,93 @@ type Appointment struct {
 }
 
 type TaskEvent struct {
-	*Task  `json:"-"`
-	Title  string `json:"title"`
-	Start  Time   `json:"start"`
-	End    Time   `json:"end"`
-	Finish bool   `json:"finish"`
+	*Task     `json:"-"`
+	Title     string  `json:"title"`
+	Start     Time    `json:"start"`
+	End       Time    `json:"end"`
+	Finish    bool    `json:"finish"`
+	Late      bool    `json:"late,omitempty"`
+	LateHours float64 `json:"lateHours,omitempty"`
+	Assignee  string  `json:"assignee,omitempty"`
 }
 
 type Task struct {
+	ID                      string
 	Title                   string
 	EstimatedHours          float64
+	HoursCompleted          float64
+	RemainingHours          *float64
 	Reward                  float64
 	Deadline                Time
 	DeadlineHourIndex       int
+	DeadlineType            string
+	LatePenaltyPerHour      float64
 	StartOnOrAfter          Time
 	StartOnOrAfterHourIndex int
-	hoursScheduled          float64
+	Recurrence              *Recurrence
+	People                  []string
+	people                  []int
+	AllowedBlocks           []TaskBlock
+	PreferredBlocks         []TaskBlock
+	DependsOn               []string
+	dependsOn               []int
+	MinChunkHours           float64
+	MaxChunkHours           float64
+	MaxHoursPerDay          float64
+	MaxHoursPerWeek         float64
+	minChunkSlots           int
+	maxChunkSlots           int
+	maxSlotsPerDay          int
+	maxSlotsPerWeek         int
+	firstHour               int
+	cols                    []int
+	chunkStartCol           int
+	estimatedSlots          int
+	slotsScheduled          int
+	rewardNudge             float64
+	allowedSlots            []bool
+	preferredSlots          []bool
+	lockedSlots             []int
+	plannedSlots            []int
+	relaxDeadline           bool
+	softDeadline            bool
+	lateCol                 int
+	lateSlots               int
+	requestIndex            int
+}
+
+// The path in the request of one of the task's fields, like "tasks[2].maxChunkHours". The instances
+// of a recurring task have the index of the task they came from.
+func (task Task) field(name string) string {
+	return fmt.Sprintf("tasks[%d].%s", task.requestIndex, name)
+}
+
+const (
+	HardDeadline = "hard"
+	SoftDeadline = "soft"
+)
+
+// Reduce each task's estimate to the hours still left to do on it, which is its remainingHours if
+// given, or otherwise its estimatedHours less its hoursCompleted
+func (tp *TaskParams) applyTaskProgress() {
+	for i := range tp.Tasks {
+		task := &tp.Tasks[i]
+		if task.RemainingHours != nil {
+			task.EstimatedHours = *task.RemainingHours
+		} else if task.HoursCompleted != 0 {
+			task.EstimatedHours = math.Max(task.EstimatedHours-task.HoursCompleted, 0)
+		}
+	}
+}
+
+// Whether the task has a hard deadline within the given number of task hours
+func (task Task) hasDeadline(numHours int) bool {
+	return !task.softDeadline && task.DeadlineHourIndex >= 0 && task.DeadlineHourIndex < numHours
+}
+
+// Whether the task's hard deadline has to be met, as addDeadlineConstraints would require
+func (tp TaskParams) hardDeadline(task Task) bool {
+	return task.hasDeadline(len(tp.TaskHours)) && !task.relaxDeadline
 }
 
 type TimeBlock struct {
@@ -204,6 +612,13 @@ func (t TimeWithoutDate) MarshalJSON() ([]byte, error) {
 
 func (t *TimeWithoutDate) UnmarshalJSON(b []byte) error {
 	s := string(b)
+	if s == "null" {
+		return nil
+	}
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		// The decoder adds the field to a type error, so it's reported like any other wrong type
+		return &json.UnmarshalTypeError{Value: jsonValueKind(b), Type: reflect.TypeOf(*t)}
+	}
 	s = s[1 : len(s)-1] // Get rid of the enclosing quotes
 	timeParts := strings.Split(s, ":")
 	if len(timeParts) != 2 {
@@ -222,46 +637,123 @@ func (t *TimeWithoutDate) UnmarshalJSON(b []byte) error {
 	return nil
 }
 
+// The kind of a JSON value as the decoder names it in an UnmarshalTypeError
+func jsonValueKind(b []byte) string {
+	switch b[0] {
+	case '{':
+		return "object"
+	case '[':
+		return "array"
+	case 't', 'f':
+		return "bool"
+	case '"':
+		return "string"
+	}
+	return "number"
+}
+
+const DefaultSlotMinutes = 60
+
+// The length of each of the TaskHours slots that tasks are scheduled in
+func (tp TaskParams) slotDuration() Duration {
+	return Duration(tp.SlotMinutes) * Minute
+}
+
+// Round a number of hours up to a whole number of slots
+func (tp TaskParams) hoursAsSlots(hours float64) int {
+	return int(math.Ceil(hours*60/float64(tp.SlotMinutes) - 1e-9))
+}
+
+// Round a number of hours down to a whole number of slots
+func (tp TaskParams) hoursAsWholeSlots(hours float64) int {
+	return int(math.Floor(hours*60/float64(tp.SlotMinutes) + 1e-9))
+}
+
+func (tp TaskParams) setChunkSlots(task *Task) error {
+	if task.MinChunkHours > 0 {
+		task.minChunkSlots = tp.hoursAsSlots(task.MinChunkHours)
+		if task.minChunkSlots > task.estimatedSlots {
+			// The whole task is then one chunk
+			task.minChunkSlots = task.estimatedSlots
+		}
+	}
+	if task.MaxChunkHours > 0 {
+		task.maxChunkSlots = tp.hoursAsWholeSlots(task.MaxChunkHours)
+		if task.maxChunkSlots < 1 {
+			return fieldError(task.field("maxChunkHours"), "must be at least one slot long")
+		}
+		if task.maxChunkSlots < task.minChunkSlots {
+			return fieldError(task.field("maxChunkHours"), "must not be less than minChunkHours")
+		}
+	}
+	return nil
+}
+
+func (tp TaskParams) setPeriodSlots(task *Task) error {
+	if task.MaxHoursPerDay > 0 {
+		task.maxSlotsPerDay = tp.hoursAsWholeSlots(task.MaxHoursPerDay)
+		if task.maxSlotsPerDay < 1 {
+			return fieldError(task.field("maxHoursPerDay"), "must be at least one slot long")
+		}
+	}
+	if task.MaxHoursPerWeek > 0 {
+		task.maxSlotsPerWeek = tp.hoursAsWholeSlots(task.MaxHoursPerWeek)
+		if task.maxSlotsPerWeek < 1 {
+			return fieldError(task.field("maxHoursPerWeek"), "must be at least one slot long")
+		}
+	}
+	return nil
+}
+
 func (tp TaskParams) moveTimeToNextBlock(t *Time) (blockEnd Time) {
 	blockStart := Time{}
 	blockEnd = Time{}
 	for weekdays := 0; weekdays < 7; weekdays++ {
+		year, month, day := t.Date()
 		for _, block := range tp.WeeklyTaskBlocks[t.Weekday()] {
-			year, month, day := t.Truncate(Hour).Date()
 			blockStart = Date(year, month, day, block.Start.Hour(), block.Start.Minute(), 0, 0, tp.Location)
 			blockEnd = Date(year, month, day, block.End.Hour(), block.End.Minute(), 0, 0, tp.Location)
 
 			if t.Before(blockStart) {
 				*t = blockStart
 			}
-			if !t.Add(Hour).After(blockEnd) {
+			if !t.Add(tp.slotDuration()).After(blockEnd) {
 				return blockEnd
 			}
 		}
-		*t = t.Add(Duration(24-t.Hour()) * Hour)
+		*t = Date(year, month, day+1, 0, 0, 0, 0, tp.Location)
 	}
 	return blockEnd
 }
 
 func (tp *TaskParams) calculateTaskHours() {
+	if len(tp.People) > 0 {
+		tp.calculatePeopleTaskHours()
+		return
+	}
+	tp.TaskHours = tp.availableSlots()
+}
+
+// The slots within the weekly task blocks that aren't taken by appointments
+func (tp TaskParams) availableSlots() []Time {
 	taskHours := make([]Time, 0)
 	t := tp.StartTaskSchedule
 	blockEnd := tp.moveTimeToNextBlock(&t)
-	hourAhead := t.Add(Hour)
+	slotAhead := t.Add(tp.slotDuration())
 
-	for !hourAhead.After(tp.EndTaskSchedule) {
-		if hourAhead.After(blockEnd) {
+	for !slotAhead.After(tp.EndTaskSchedule) {
+		if slotAhead.After(blockEnd) {
 			blockEnd = tp.moveTimeToNextBlock(&t)
 		} else {
-			if !tp.appointmentInRange(t, hourAhead) {
+			if !tp.appointmentInRange(t, slotAhead) {
 				taskHours = append(taskHours, t)
 			}
-			t = hourAhead
+			t = slotAhead
 		}
-		hourAhead = t.Add(Hour)
+		slotAhead = t.Add(tp.slotDuration())
 	}
 
-	tp.TaskHours = taskHours
+	return taskHours
 }
 
 // Could probably be made more efficient
@@ -283,8 +775,8 @@ func (tp TaskParams) deadlineAsTaskHour(deadline Time) int {
 	}
 
 	for taskHour := len(tp.TaskHours) - 1; taskHour >= 0; taskHour-- {
-		hourAhead := tp.TaskHours[taskHour].Add(Hour)
-		if !hourAhead.After(deadline) {
+		slotAhead := tp.TaskHours[taskHour].Add(tp.slotDuration())
+		if !slotAhead.After(deadline) {
 			return taskHour
 		}
 	}
@@ -308,7 +800,7 @@ func (tp TaskParams) onOrAfterAsTaskHour(onOrAfter Time) int {
 
 func (tp TaskParams) deadlineInPastErr() error {
 	for _, task := range tp.Tasks {
-		if task.DeadlineHourIndex < 0 {
+		if task.DeadlineHourIndex < 0 && !task.softDeadline {
 			return errors.New("Deadline in the past for task: " + task.Title)
 		}
 	}
@@ -323,27 +815,150 @@ func (tp *TaskParams) calculateSchedule() error {
 }
 
 func (tp *TaskParams) setupLP() error {
-	ncol := len(tp.Tasks) * len(tp.TaskHours)
-
-	tp.lp = golp.NewLP(0, ncol)
+	tp.setColumns()
+	tp.lp = newSolver(tp.SolverName, tp.numCols)
 	tp.setColNames()
+	if tp.Binary {
+		tp.setBinary()
+	}
+	tp.addAllowedBlockBounds()
+	tp.addLockedBounds()
 	tp.addHourConstraints()
 	tp.addTaskConstraints()
 	tp.addDeadlineConstraints()
 	tp.addStartContraints()
-	tp.addObjectiveFunction()
+	tp.addDependencyConstraints()
+	tp.addChunkConstraints()
+	tp.addPeriodConstraints()
+	tp.lp.SetObjFn(tp.objectiveRow(), true)
 
 	return nil
 }
 
+// Number the columns: one for each hour in each task's window, then the chunk start and late columns
+func (tp *TaskParams) setColumns() {
+	lastHours := make([]int, len(tp.Tasks))
+	for taskNum := range tp.Tasks {
+		task := &tp.Tasks[taskNum]
+		task.firstHour, lastHours[taskNum] = tp.columnWindow(*task)
+		task.cols = make([]int, 0)
+	}
+	ncol := 0
+	for hour := range tp.TaskHours {
+		for taskNum := range tp.Tasks {
+			if task := &tp.Tasks[taskNum]; hour >= task.firstHour && hour <= lastHours[taskNum] {
+				task.cols = append(task.cols, ncol)
+				ncol++
+			}
+		}
+	}
+
+	// Tasks with a minimum chunk length get an extra column per hour marking where their chunks start
+	for taskNum := range tp.Tasks {
+		if task := &tp.Tasks[taskNum]; task.minChunkSlots > 1 {
+			task.chunkStartCol = ncol
+			ncol += len(task.cols)
+		}
+	}
+
+	// Tasks with soft deadlines get a column for the number of hours not done by the deadline
+	for taskNum := range tp.Tasks {
+		if tp.Tasks[taskNum].softDeadline {
+			tp.Tasks[taskNum].lateCol = ncol
+			ncol++
+		}
+	}
+
+	tp.numCols = ncol
+}
+
+// The hours a task gets columns for, from its start to its hard deadline or the last hour. The
+// task can't be done outside them, so columns there would only be forced to zero. Locked slots
+// outside are kept in so the start and deadline constraints can make them infeasible, as is the
+// deadline hour of a task that can't start by its deadline.
+func (tp TaskParams) columnWindow(task Task) (first, last int) {
+	first, last = task.StartOnOrAfterHourIndex, len(tp.TaskHours)-1
+	if first < 0 {
+		first = len(tp.TaskHours)
+	}
+	if tp.hardDeadline(task) {
+		last = task.DeadlineHourIndex
+		if first > last {
+			first = last
+		}
+	}
+	for _, hour := range task.lockedSlots {
+		if hour < first {
+			first = hour
+		}
+		if hour > last {
+			last = hour
+		}
+	}
+	return first, last
+}
+
+// The column of the task in the hour, or -1 if the hour is outside the task's window
 func (tp TaskParams) col(hour, taskNum int) int {
-	return hour*len(tp.Tasks) + taskNum
+	task := &tp.Tasks[taskNum]
+	if hour < task.firstHour || hour >= task.firstHour+len(task.cols) {
+		return -1
+	}
+	return task.cols[hour-task.firstHour]
+}
+
+// The hours of the task's window, for looping over its columns
+func (tp TaskParams) windowHours(taskNum int) (first, end int) {
+	task := &tp.Tasks[taskNum]
+	return task.firstHour, task.firstHour + len(task.cols)
+}
+
+func (tp TaskParams) chunkStartCol(hour, taskNum int) int {
+	return tp.Tasks[taskNum].chunkStartCol + hour - tp.Tasks[taskNum].firstHour
+}
+
+// Return the index of the hour just before the given one if the two are contiguous (and of the same
+// person), otherwise -1
+func (tp TaskParams) prevSlot(hour int) int {
+	prevStart := tp.TaskHours[hour].Add(-tp.slotDuration())
+	for prev := hour - 1; prev >= 0 && !tp.TaskHours[prev].Before(prevStart); prev-- {
+		if tp.TaskHours[prev].Equal(prevStart) && tp.slotPerson(prev) == tp.slotPerson(hour) {
+			return prev
+		}
+	}
+	return -1
+}
+
+func (tp TaskParams) nextSlot(hour int) int {
+	nextStart := tp.TaskHours[hour].Add(tp.slotDuration())
+	for next := hour + 1; next < len(tp.TaskHours) && !tp.TaskHours[next].After(nextStart); next++ {
+		if tp.TaskHours[next].Equal(nextStart) && tp.slotPerson(next) == tp.slotPerson(hour) {
+			return next
+		}
+	}
+	return -1
 }
 
 func (tp *TaskParams) setColNames() {
-	for hour := 0; hour < len(tp.TaskHours); hour++ {
-		for taskNum := 0; taskNum < len(tp.Tasks); taskNum++ {
+	for taskNum, task := range tp.Tasks {
+		first, end := tp.windowHours(taskNum)
+		for hour := first; hour < end; hour++ {
 			tp.lp.SetColName(tp.col(hour, taskNum), "h"+strconv.Itoa(hour)+"_t"+strconv.Itoa(taskNum))
+			if task.minChunkSlots > 1 {
+				tp.lp.SetColName(tp.chunkStartCol(hour, taskNum), "s"+strconv.Itoa(hour)+"_t"+strconv.Itoa(taskNum))
+			}
+		}
+		if task.softDeadline {
+			tp.lp.SetColName(task.lateCol, "late_t"+strconv.Itoa(taskNum))
+		}
+	}
+}
+
+// Declare every hour and task column binary so the solution can't have fractional hours
+func (tp *TaskParams) setBinary() {
+	for _, task := range tp.Tasks {
+		for _, col := range task.cols {
+			tp.lp.SetBinary(col, true)
 		}
 	}
 }
@@ -351,76 +966,268 @@ func (tp *TaskParams) setColNames() {
 func (tp *TaskParams) addHourConstraints() {
 	// Total tasks done in a hour must be <= 1
 	for hour := 0; hour < len(tp.TaskHours); hour++ {
-		entries := make([]golp.Entry, len(tp.Tasks))
+		entries := make([]Entry, 0, len(tp.Tasks))
 		for taskNum := 0; taskNum < len(tp.Tasks); taskNum++ {
-			entries[taskNum].Col = tp.col(hour, taskNum)
-			entries[taskNum].Val = 1.0
+			if col := tp.col(hour, taskNum); col >= 0 {
+				entries = append(entries, Entry{Col: col, Val: 1.0})
+			}
+		}
+		if len(entries) > 0 {
+			tp.lp.AddConstraintSparse(entries, LE, 1.0)
 		}
-		tp.lp.AddConstraintSparse(entries, golp.LE, 1.0)
 	}
 }
 
 func (tp *TaskParams) addTaskConstraints() {
-	// Total amount done on each task must be <= task.EstimatedHours
-	for taskNum, task := range tp.Tasks {
-		entries := make([]golp.Entry, len(tp.TaskHours))
-		for hour := 0; hour < len(tp.TaskHours); hour++ {
-			entries[hour].Col = tp.col(hour, taskNum)
-			entries[hour].Val = 1.0
+	// Total amount done on each task must be <= task.EstimatedHours (as a number of slots)
+	for _, task := range tp.Tasks {
+		if len(task.cols) == 0 {
+			continue
 		}
-		tp.lp.AddConstraintSparse(entries, golp.LE, task.EstimatedHours)
+		entries := make([]Entry, len(task.cols))
+		for i, col := range task.cols {
+			entries[i].Col = col
+			entries[i].Val = 1.0
+		}
+		tp.lp.AddConstraintSparse(entries, LE, float64(task.estimatedSlots))
 	}
 }
 
+// The entries for the task's columns from its first hour up to and including the given one
+func (tp TaskParams) entriesThrough(hour, taskNum int) []Entry {
+	entries := make([]Entry, 0)
+	first, end := tp.windowHours(taskNum)
+	for ; first <= hour && first < end; first++ {
+		entries = append(entries, Entry{Col: tp.col(first, taskNum), Val: 1.0})
+	}
+	return entries
+}
+
 func (tp *TaskParams) addDeadlineConstraints() {
 	// Total amount done on task with deadline up to the deadline hour index must equal the estimated hours
 	for taskNum, task := range tp.Tasks {
-		if task.DeadlineHourIndex < len(tp.TaskHours) && task.DeadlineHourIndex >= 0 {
-			entries := make([]golp.Entry, task.DeadlineHourIndex+1)
-			for hour := 0; hour <= task.DeadlineHourIndex; hour++ {
-				entries[hour].Col = tp.col(hour, taskNum)
-				entries[hour].Val = 1.0
-			}
-			tp.lp.AddConstraintSparse(entries, golp.EQ, task.EstimatedHours)
+		if task.softDeadline {
+			tp.addSoftDeadlineConstraint(taskNum)
+		} else if tp.hardDeadline(task) {
+			// columnWindow keeps at least the deadline hour, so there are always entries
+			tp.lp.AddConstraintSparse(tp.entriesThrough(task.DeadlineHourIndex, taskNum), EQ, float64(task.estimatedSlots))
 		}
 	}
 }
 
+func (tp *TaskParams) addSoftDeadlineConstraint(taskNum int) {
+	// The hours done on a task with a soft deadline up to the deadline hour index plus the hours it is
+	// late by must equal the estimated hours. The late hours are penalized in the objective function.
+	task := tp.Tasks[taskNum]
+	if task.DeadlineHourIndex >= len(tp.TaskHours) {
+		return
+	}
+	entries := append([]Entry{{Col: task.lateCol, Val: 1.0}}, tp.entriesThrough(task.DeadlineHourIndex, taskNum)...)
+	tp.lp.AddConstraintSparse(entries, EQ, float64(task.estimatedSlots))
+}
+
 func (tp *TaskParams) addStartContraints() {
-	// Total amount done on task with deadline up to the deadline hour index must equal zero
+	// Total amount done on a task before its start hour index must equal zero. Tasks only have
+	// columns before it when columnWindow kept them in.
+	for taskNum, task := range tp.Tasks {
+		startIndex := task.StartOnOrAfterHourIndex
+		if startIndex < 0 {
+			// Can't start in the time horizon given, so none of it can be done
+			startIndex = len(tp.TaskHours)
+		}
+		if task.firstHour < startIndex && len(task.cols) > 0 {
+			tp.lp.AddConstraintSparse(tp.entriesThrough(startIndex-1, taskNum), EQ, 0.0)
+		}
+	}
+}
+
+func (tp *TaskParams) addDependencyConstraints() {
+	// A dependent task can only be done in an hour once all the estimated hours of each of its
+	// prerequisites have been done in the hours that end before it starts, i.e. for each hour:
+	// prereq.EstimatedHours * dependent[hour] - sum(prereq[0..hour-1]) <= 0
+	// (With people, the slots of others at the same time as the hour aren't before it.)
+	// Those rows allow fractional hours in the relaxation, so both tasks are made integer.
 	for taskNum, task := range tp.Tasks {
-		if task.StartOnOrAfterHourIndex > 0 {
-			entries := make([]golp.Entry, task.StartOnOrAfterHourIndex)
-			for hour := 0; hour < task.StartOnOrAfterHourIndex; hour++ {
-				entries[hour].Col = tp.col(hour, taskNum)
-				entries[hour].Val = 1.0
+		for _, prereqNum := range task.dependsOn {
+			prereq := tp.Tasks[prereqNum]
+			tp.setTaskInt(taskNum)
+			tp.setTaskInt(prereqNum)
+			first, end := tp.windowHours(taskNum)
+			for hour := first; hour < end; hour++ {
+				entries := make([]Entry, 1, hour+1)
+				entries[0].Col = tp.col(hour, taskNum)
+				entries[0].Val = float64(prereq.estimatedSlots)
+				for before := 0; before < hour && !tp.TaskHours[before].Add(tp.slotDuration()).After(tp.TaskHours[hour]); before++ {
+					if col := tp.col(before, prereqNum); col >= 0 {
+						entries = append(entries, Entry{Col: col, Val: -1.0})
+					}
+				}
+				tp.lp.AddConstraintSparse(entries, LE, 0.0)
 			}
-			tp.lp.AddConstraintSparse(entries, golp.EQ, 0.0)
 		}
 	}
 }
 
-func (tp *TaskParams) addObjectiveFunction() {
-	// Objective function
-	decayRate := 0.99
-	curHourValue := 1.0
-	row := make([]float64, len(tp.Tasks)*len(tp.TaskHours))
-	for hour := 0; hour < len(tp.TaskHours); hour++ {
-		for taskNum, task := range tp.Tasks {
-			taskLengthPenalty := math.Pow(decayRate, task.EstimatedHours)
-			row[tp.col(hour, taskNum)] = curHourValue * taskLengthPenalty * task.Reward / task.EstimatedHours
+func (tp *TaskParams) setTaskInt(taskNum int) {
+	for _, col := range tp.Tasks[taskNum].cols {
+		tp.lp.SetInt(col, true)
+	}
+}
+
+func (tp *TaskParams) addChunkConstraints() {
+	for taskNum, task := range tp.Tasks {
+		if task.minChunkSlots > 1 {
+			tp.setTaskInt(taskNum)
+			tp.addMinChunkConstraints(taskNum)
+		}
+		if task.maxChunkSlots > 0 {
+			tp.setTaskInt(taskNum)
+			tp.addMaxChunkConstraints(taskNum)
+		}
+	}
+}
+
+func (tp *TaskParams) addMinChunkConstraints(taskNum int) {
+	minChunkSlots := tp.Tasks[taskNum].minChunkSlots
+	first, end := tp.windowHours(taskNum)
+	for hour := first; hour < end; hour++ {
+		// A chunk starts in an hour if the task is done then but not in the contiguous hour before:
+		// task[hour] - task[prev] - start[hour] <= 0
+		entries := []Entry{
+			{Col: tp.col(hour, taskNum), Val: 1.0},
+			{Col: tp.chunkStartCol(hour, taskNum), Val: -1.0},
+		}
+		if prev := tp.prevSlot(hour); prev >= 0 && tp.col(prev, taskNum) >= 0 {
+			entries = append(entries, Entry{Col: tp.col(prev, taskNum), Val: -1.0})
+		}
+		tp.lp.AddConstraintSparse(entries, LE, 0.0)
+
+		// Once a chunk starts the task must continue for the following contiguous hours:
+		// start[hour] - task[next] <= 0, and a chunk can't start if too few contiguous hours follow
+		// (or they run past the task's window).
+		next := hour
+		for i := 1; i < minChunkSlots; i++ {
+			next = tp.nextSlot(next)
+			if next < 0 || tp.col(next, taskNum) < 0 {
+				entries = []Entry{{Col: tp.chunkStartCol(hour, taskNum), Val: 1.0}}
+				tp.lp.AddConstraintSparse(entries, LE, 0.0)
+				break
+			}
+			entries = []Entry{
+				{Col: tp.chunkStartCol(hour, taskNum), Val: 1.0},
+				{Col: tp.col(next, taskNum), Val: -1.0},
+			}
+			tp.lp.AddConstraintSparse(entries, LE, 0.0)
 		}
-		curHourValue *= decayRate
 	}
-	tp.lp.SetObjFn(row, true)
 }
 
-func (tp *TaskParams) interpretTaskSchedule() error {
-	vars := tp.lp.GetVariables()
+func (tp *TaskParams) addMaxChunkConstraints(taskNum int) {
+	// Within any run of maxChunkSlots+1 contiguous hours at most maxChunkSlots can be spent on the task.
+	// Hours outside the task's window can't be spent on it, so runs stop at the end of the window.
+	maxChunkSlots := tp.Tasks[taskNum].maxChunkSlots
+	first, end := tp.windowHours(taskNum)
+	for hour := first; hour < end; hour++ {
+		entries := make([]Entry, 0, maxChunkSlots+1)
+		for next := hour; next >= 0 && tp.col(next, taskNum) >= 0 && len(entries) <= maxChunkSlots; next = tp.nextSlot(next) {
+			entries = append(entries, Entry{Col: tp.col(next, taskNum), Val: 1.0})
+		}
+		if len(entries) > maxChunkSlots {
+			tp.lp.AddConstraintSparse(entries, LE, float64(maxChunkSlots))
+		}
+	}
+}
+
+// The local calendar day and ISO week of an hour, used to group hours for the per day and week limits
+func dayOfHour(t Time) int {
+	return t.Year()*1000 + t.YearDay()
+}
+
+func weekOfHour(t Time) int {
+	year, week := t.ISOWeek()
+	return year*100 + week
+}
+
+func (tp *TaskParams) addPeriodConstraints() {
+	for taskNum, task := range tp.Tasks {
+		if task.maxSlotsPerDay > 0 {
+			tp.setTaskInt(taskNum)
+			tp.addPeriodConstraint(taskNum, task.maxSlotsPerDay, dayOfHour)
+		}
+		if task.maxSlotsPerWeek > 0 {
+			tp.setTaskInt(taskNum)
+			tp.addPeriodConstraint(taskNum, task.maxSlotsPerWeek, weekOfHour)
+		}
+	}
+}
+
+func (tp *TaskParams) addPeriodConstraint(taskNum, maxSlots int, period func(Time) int) {
+	// Total amount done on the task by each person in the hours of each of their periods must be
+	// <= maxSlots. The slots of people in different time zones are interleaved, so the hours of a
+	// period needn't be contiguous.
+	type personPeriod struct {
+		personNum, period int
+	}
+	periods := make([]personPeriod, 0)
+	entries := make(map[personPeriod][]Entry)
+	first, end := tp.windowHours(taskNum)
+	for hour := first; hour < end; hour++ {
+		col := tp.col(hour, taskNum)
+		if col < 0 {
+			continue
+		}
+		key := personPeriod{tp.slotPerson(hour), period(tp.TaskHours[hour].In(tp.slotLocation(hour)))}
+		if _, ok := entries[key]; !ok {
+			periods = append(periods, key)
+		}
+		entries[key] = append(entries[key], Entry{Col: col, Val: 1.0})
+	}
+	for _, key := range periods {
+		if len(entries[key]) > maxSlots {
+			tp.lp.AddConstraintSparse(entries[key], LE, float64(maxSlots))
+		}
+	}
+}
+
+// The objective function coefficient of each column, which is also kept as tp.objective
+func (tp *TaskParams) objectiveRow() []float64 {
+	slotValues := tp.slotValues()
+	row := make([]float64, tp.numCols)
+	for taskNum, task := range tp.Tasks {
+		if task.estimatedSlots == 0 {
+			// Nothing left to do for the task
+			continue
+		}
+		slotReward := tp.rewardFactor(task) * task.Reward * task.rewardNudge / float64(task.estimatedSlots)
+		first, end := tp.windowHours(taskNum)
+		for hour := first; hour < end; hour++ {
+			row[tp.col(hour, taskNum)] = slotValues[hour] * slotReward * tp.preferredBlockFactor(task, hour)
+		}
+		// Keeping a planned event where it was avoids the stability penalty for moving it
+		for _, hour := range task.plannedSlots {
+			if col := tp.col(hour, taskNum); col >= 0 {
+				row[col] += tp.Objective.StabilityPenalty * tp.slotDuration().Hours()
+			}
+		}
+	}
+	for _, task := range tp.Tasks {
+		if task.softDeadline {
+			row[task.lateCol] = -task.LatePenaltyPerHour * tp.slotDuration().Hours()
+		}
+	}
+	tp.objective = row
+	return row
+}
+
+func (tp *TaskParams) interpretTaskSchedule(vars []float64) error {
 	tp.TaskSchedule = make([]*Task, len(tp.TaskHours))
 	for hour := 0; hour < len(tp.TaskHours); hour++ {
 		for taskNum := 0; taskNum < len(tp.Tasks); taskNum++ {
-			val := vars[tp.col(hour, taskNum)]
+			col := tp.col(hour, taskNum)
+			if col < 0 {
+				continue
+			}
+			val := vars[col]
 
 			delta := 0.001
 			if math.Abs(val-1.0) < delta {
@@ -430,33 +1237,55 @@ func (tp *TaskParams) interpretTaskSchedule() error {
 			}
 		}
 	}
+	for taskNum := range tp.Tasks {
+		if task := &tp.Tasks[taskNum]; task.softDeadline {
+			task.lateSlots = int(math.Floor(vars[task.lateCol] + 0.5))
+		}
+	}
 	return nil
 }
 
 func (tp *TaskParams) formatTaskEvents() {
 	tp.TaskEvents = make([]TaskEvent, 0)
 
-	var hourAhead Time
-	var prevTask *Task
+	// The latest event and its number of slots for each person, as their slots may be interleaved
+	personEvent := make(map[int]int)
+	eventSlots := make(map[int]int)
 	for i, task := range tp.TaskSchedule {
-		if task != nil {
-			if prevTask != task || tp.TaskHours[i].After(hourAhead) {
-				var newEvent TaskEvent
-				newEvent.Start = tp.TaskHours[i]
-				newEvent.Task = task
-				newEvent.Title = task.Title
-				tp.TaskEvents = append(tp.TaskEvents, newEvent)
-			}
+		if task == nil {
+			continue
+		}
+		person := tp.slotPerson(i)
+		prev := tp.prevSlot(i)
+		chunkFull := task.maxChunkSlots > 0 && eventSlots[person] >= task.maxChunkSlots
+		if prev < 0 || tp.TaskSchedule[prev] != task || chunkFull {
+			var newEvent TaskEvent
+			newEvent.Start = tp.TaskHours[i]
+			newEvent.Task = task
+			newEvent.Title = task.Title
+			newEvent.Assignee = tp.slotPersonName(i)
+			tp.TaskEvents = append(tp.TaskEvents, newEvent)
+			personEvent[person] = len(tp.TaskEvents) - 1
+			eventSlots[person] = 0
+		}
+		eventSlots[person]++
 
-			event := &tp.TaskEvents[len(tp.TaskEvents)-1]
-			task.hoursScheduled++
-			if task.hoursScheduled >= task.EstimatedHours {
-				event.Finish = true
+		event := &tp.TaskEvents[personEvent[person]]
+		task.slotsScheduled++
+		if task.slotsScheduled >= task.estimatedSlots {
+			event.Finish = true
+		}
+		event.End = tp.TaskHours[i].Add(tp.slotDuration())
+	}
+
+	for i := 0; i < len(tp.TaskEvents); i++ {
+		event := &tp.TaskEvents[i]
+		if event.Task.softDeadline && event.Task.lateSlots > 0 && event.End.After(event.Task.Deadline) {
+			event.Late = true
+			if event.Finish {
+				event.LateHours = event.End.Sub(event.Task.Deadline).Hours()
 			}
-			hourAhead = tp.TaskHours[i].Add(Hour)
-			event.End = hourAhead
 		}
-		prevTask = task
 	}
 
 	for i := 0; i < len(tp.TaskEvents); i++ {
@@ -2,6 +2,8 @@ package main
 
 import (
 	"encoding/json"
+	"fmt"
+	"strings"
 	"testing"
 	. "time"
 
@@ -237,3 +239,676 @@ func TestCalcSchedule(t *testing.T) {
 		So(actualParsed, ShouldResemble, expectedParsed)
 	})
 }
+
+func TestDependencies(t *testing.T) {
+	in := []byte(`{
+		"timeZone": "America/New_York",
+		"weeklyTaskBlocks": [
+			[],
+			[{"start": "10:00", "end": "12:00"}],
+			[{"start": "9:00", "end": "10:00"}, {"start": "11:30", "end": "14:30"}],
+			[],
+			[],
+			[{"start": "16:00", "end": "18:00"}],
+			[]
+		],
+		"appointments": [	],
+		"tasks": [
+			{"title": "Send newsletter", "estimatedHours": 1, "reward": 20, "dependsOn": ["draft"]},
+			{"id": "draft", "title": "Draft newsletter", "estimatedHours": 2, "reward": 4},
+			{"title": "Admin", "estimatedHours": 1, "reward": 3}
+		],
+		"startTaskSchedule": "2015-02-16T14:00:00Z",
+		"endTaskSchedule": "2015-02-28T22:00:00Z"
+	}`)
+
+	expectedOut := []byte(`[
+	    { "title": "Draft newsletter", "start": "2015-02-16T15:00:00Z", "end": "2015-02-16T17:00:00Z", "finish": true },
+	    { "title": "Send newsletter", "start": "2015-02-17T14:00:00Z", "end": "2015-02-17T15:00:00Z", "finish": true },
+	    { "title": "Admin", "start": "2015-02-17T16:30:00Z", "end": "2015-02-17T17:30:00Z", "finish": true }
+	  ]`)
+
+	Convey("With a task depending on another, it schedules the prerequisite to finish first", t, func() {
+		actualOut, err := parseAndComputeSchedule(in)
+		So(err, ShouldBeNil)
+
+		var expectedParsed []interface{}
+		err = json.Unmarshal(expectedOut, &expectedParsed)
+		So(err, ShouldBeNil)
+
+		var actualParsed []interface{}
+		err = json.Unmarshal(actualOut, &actualParsed)
+		So(err, ShouldBeNil)
+
+		So(actualParsed, ShouldResemble, expectedParsed)
+	})
+
+	Convey("Unknown and cyclic dependencies are rejected", t, func() {
+		var tp TaskParams
+		err := parseTaskParams([]byte(`{
+			"timeZone": "America/New_York",
+			"weeklyTaskBlocks": [[], [], [], [], [], [], []],
+			"startTaskSchedule": "2015-02-16T14:00:00Z",
+			"endTaskSchedule": "2015-02-28T22:00:00Z",
+			"tasks": [{"title": "Send", "estimatedHours": 1, "reward": 1, "dependsOn": ["Draft"]}]
+		}`), &tp)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "tasks[0].dependsOn[0] is not the id or title of a task: Draft")
+
+		err = parseTaskParams([]byte(`{
+			"timeZone": "America/New_York",
+			"weeklyTaskBlocks": [[], [], [], [], [], [], []],
+			"startTaskSchedule": "2015-02-16T14:00:00Z",
+			"endTaskSchedule": "2015-02-28T22:00:00Z",
+			"tasks": [
+				{"title": "A", "estimatedHours": 1, "reward": 1, "dependsOn": ["B"]},
+				{"title": "B", "estimatedHours": 1, "reward": 1, "dependsOn": ["C"]},
+				{"title": "C", "estimatedHours": 1, "reward": 1, "dependsOn": ["B"]}
+			]
+		}`), &tp)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "tasks[2].dependsOn makes a dependency cycle: B -> C -> B")
+	})
+}
+
+func TestSlotMinutes(t *testing.T) {
+	in := []byte(`{
+		"timeZone": "America/New_York",
+		"slotMinutes": 15,
+		"weeklyTaskBlocks": [
+			[],
+			[{"start": "9:00", "end": "9:45"}, {"start": "13:00", "end": "14:00"}],
+			[],
+			[],
+			[],
+			[],
+			[]
+		],
+		"appointments": [{"title": "Call", "start": "2015-02-16T18:15:00Z", "end": "2015-02-16T18:30:00Z"}],
+		"tasks": [
+			{"title": "Email", "estimatedHours": 0.5, "reward": 4},
+			{"title": "Expenses", "estimatedHours": 0.6, "reward": 3}
+		],
+		"startTaskSchedule": "2015-02-16T14:00:00Z",
+		"endTaskSchedule": "2015-02-17T22:00:00Z"
+	}`)
+
+	Convey("With 15 minute slots, it uses partial hour blocks and rounds estimates up to whole slots", t, func() {
+		var tp TaskParams
+		err := parseTaskParams(in, &tp)
+		So(err, ShouldBeNil)
+
+		EST, err := LoadLocation("America/New_York")
+		So(err, ShouldBeNil)
+		So(tp.TaskHours, ShouldResemble, []Time{
+			Date(2015, 2, 16, 9, 0, 0, 0, EST),
+			Date(2015, 2, 16, 9, 15, 0, 0, EST),
+			Date(2015, 2, 16, 9, 30, 0, 0, EST),
+			Date(2015, 2, 16, 13, 0, 0, 0, EST),
+			Date(2015, 2, 16, 13, 30, 0, 0, EST),
+			Date(2015, 2, 16, 13, 45, 0, 0, EST),
+		})
+		So(tp.Tasks[0].estimatedSlots, ShouldEqual, 2)
+		So(tp.Tasks[1].estimatedSlots, ShouldEqual, 3)
+
+		err = tp.calcSchedule()
+		So(err, ShouldBeNil)
+		So(len(tp.TaskEvents), ShouldEqual, 4)
+		expected := []struct {
+			title      string
+			start, end Time
+			finish     bool
+		}{
+			{"Email", Date(2015, 2, 16, 9, 0, 0, 0, EST), Date(2015, 2, 16, 9, 30, 0, 0, EST), true},
+			{"Expenses", Date(2015, 2, 16, 9, 30, 0, 0, EST), Date(2015, 2, 16, 9, 45, 0, 0, EST), false},
+			{"Expenses", Date(2015, 2, 16, 13, 0, 0, 0, EST), Date(2015, 2, 16, 13, 15, 0, 0, EST), false},
+			{"Expenses", Date(2015, 2, 16, 13, 30, 0, 0, EST), Date(2015, 2, 16, 13, 45, 0, 0, EST), true},
+		}
+		for i, event := range tp.TaskEvents {
+			So(event.Title, ShouldEqual, expected[i].title)
+			So(event.Start.Equal(expected[i].start), ShouldBeTrue)
+			So(event.End.Equal(expected[i].end), ShouldBeTrue)
+			So(event.Finish, ShouldEqual, expected[i].finish)
+		}
+	})
+
+	Convey("A slot length that doesn't divide an hour is rejected", t, func() {
+		var tp TaskParams
+		err := parseTaskParams([]byte(`{"timeZone": "America/New_York", "slotMinutes": 25}`), &tp)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestChunkHours(t *testing.T) {
+	in := []byte(`{
+		"timeZone": "America/New_York",
+		"weeklyTaskBlocks": [
+			[],
+			[{"start": "10:00", "end": "16:00"}],
+			[{"start": "10:00", "end": "16:00"}],
+			[{"start": "10:00", "end": "16:00"}],
+			[],
+			[],
+			[]
+		],
+		"appointments": [{"title": "Meeting", "start": "2015-02-16T17:00:00Z", "end": "2015-02-16T18:00:00Z"}],
+		"tasks": [
+			{"title": "Writing", "estimatedHours": 5, "reward": 30, "minChunkHours": 2},
+			{"title": "Email", "estimatedHours": 7, "reward": 28, "maxChunkHours": 3},
+			{"title": "Admin", "estimatedHours": 3, "reward": 3}
+		],
+		"startTaskSchedule": "2015-02-16T14:00:00Z",
+		"endTaskSchedule": "2015-02-19T22:00:00Z"
+	}`)
+
+	Convey("With chunk lengths specified, each task is scheduled in contiguous blocks within them", t, func() {
+		var tp TaskParams
+		err := parseTaskParams(in, &tp)
+		So(err, ShouldBeNil)
+		err = tp.calcSchedule()
+		So(err, ShouldBeNil)
+
+		hoursByTitle := make(map[string]float64)
+		for i, event := range tp.TaskEvents {
+			hours := event.End.Sub(event.Start).Hours()
+			hoursByTitle[event.Title] += hours
+			switch event.Title {
+			case "Writing":
+				So(hours, ShouldBeGreaterThanOrEqualTo, 2)
+			case "Email":
+				So(hours, ShouldBeLessThanOrEqualTo, 3)
+				if i > 0 && tp.TaskEvents[i-1].Title == "Email" {
+					So(tp.TaskEvents[i-1].End.Before(event.Start), ShouldBeTrue)
+				}
+			}
+		}
+		So(hoursByTitle["Writing"], ShouldEqual, 5)
+		So(hoursByTitle["Email"], ShouldEqual, 7)
+	})
+
+	Convey("A maximum chunk shorter than the minimum is rejected", t, func() {
+		var tp TaskParams
+		err := parseTaskParams([]byte(`{
+			"timeZone": "America/New_York",
+			"weeklyTaskBlocks": [[], [], [], [], [], [], []],
+			"tasks": [{"title": "Writing", "estimatedHours": 4, "reward": 1, "minChunkHours": 3, "maxChunkHours": 2}]
+		}`), &tp)
+		So(err, ShouldNotBeNil)
+	})
+}
+
+func TestMaxHoursPerDayAndWeek(t *testing.T) {
+	in := []byte(`{
+		"timeZone": "America/New_York",
+		"weeklyTaskBlocks": [
+			[],
+			[{"start": "10:00", "end": "16:00"}],
+			[{"start": "10:00", "end": "16:00"}],
+			[{"start": "10:00", "end": "16:00"}],
+			[{"start": "10:00", "end": "16:00"}],
+			[{"start": "10:00", "end": "16:00"}],
+			[]
+		],
+		"appointments": [	],
+		"tasks": [
+			{"title": "Newsletter", "estimatedHours": 6, "reward": 60, "maxHoursPerDay": 2},
+			{"title": "Filing", "estimatedHours": 12, "reward": 48, "maxHoursPerWeek": 5},
+			{"title": "Admin", "estimatedHours": 40, "reward": 1}
+		],
+		"startTaskSchedule": "2015-02-16T14:00:00Z",
+		"endTaskSchedule": "2015-02-27T22:00:00Z"
+	}`)
+
+	Convey("With per day and per week limits, no task exceeds its hours in any day or week", t, func() {
+		var tp TaskParams
+		err := parseTaskParams(in, &tp)
+		So(err, ShouldBeNil)
+		err = tp.calcSchedule()
+		So(err, ShouldBeNil)
+
+		newsletterByDay := make(map[int]float64)
+		filingByWeek := make(map[int]float64)
+		for _, event := range tp.TaskEvents {
+			hours := event.End.Sub(event.Start).Hours()
+			switch event.Title {
+			case "Newsletter":
+				newsletterByDay[dayOfHour(event.Start.In(tp.Location))] += hours
+			case "Filing":
+				filingByWeek[weekOfHour(event.Start.In(tp.Location))] += hours
+			}
+		}
+		So(len(newsletterByDay), ShouldEqual, 3)
+		for _, hours := range newsletterByDay {
+			So(hours, ShouldEqual, 2)
+		}
+		So(len(filingByWeek), ShouldEqual, 2)
+		for _, hours := range filingByWeek {
+			So(hours, ShouldEqual, 5)
+		}
+	})
+}
+
+func TestColumnWindows(t *testing.T) {
+	in := `{
+		"timeZone": "America/New_York",
+		"weeklyTaskBlocks": [
+			[],
+			[{"start": "10:00", "end": "14:00"}],
+			[{"start": "10:00", "end": "14:00"}],
+			[],
+			[],
+			[],
+			[]
+		],
+		"tasks": [
+			{"title": "Newsletter", "estimatedHours": 2, "reward": 6, "deadline": "2015-02-16T17:00:00Z"},
+			{"title": "Study", "estimatedHours": 2, "reward": 3, "startOnOrAfter": "2015-02-17T16:00:00Z"},
+			{"title": "Review", "estimatedHours": 1, "reward": 3, "deadline": "2015-02-16T16:00:00Z", "deadlineType": "soft"}
+		],
+		"pinnedEvents": [],
+		"startTaskSchedule": "2015-02-16T14:00:00Z",
+		"endTaskSchedule": "2015-02-18T14:00:00Z"
+	}`
+
+	Convey("Tasks only get columns from their start to their hard deadline", t, func() {
+		var tp TaskParams
+		So(parseTaskParams([]byte(in), &tp), ShouldBeNil)
+		tp.setColumns()
+		So(len(tp.Tasks[0].cols), ShouldEqual, 2)
+		So(len(tp.Tasks[1].cols), ShouldEqual, 3)
+		So(len(tp.Tasks[2].cols), ShouldEqual, 8)
+		So(tp.numCols, ShouldEqual, 2+3+8+1)
+		So(tp.col(2, 0), ShouldEqual, -1)
+		So(tp.col(4, 1), ShouldEqual, -1)
+		So(tp.col(5, 1), ShouldBeGreaterThanOrEqualTo, 0)
+
+		So(tp.calcSchedule(), ShouldBeNil)
+		So(tp.TaskSchedule[0].Title, ShouldEqual, "Newsletter")
+		So(tp.TaskSchedule[5].Title, ShouldEqual, "Study")
+	})
+
+	Convey("A task that can't start before its deadline is infeasible", t, func() {
+		params := strings.Replace(in, `"deadline": "2015-02-16T17:00:00Z"`,
+			`"startOnOrAfter": "2015-02-16T15:30:00Z", "deadline": "2015-02-16T16:15:00Z"`, 1)
+		_, err := computeSchedule([]byte(params))
+		So(err, ShouldHaveSameTypeAs, &SolveError{})
+		So(err.(*SolveError).Solution, ShouldEqual, INFEASIBLE)
+	})
+
+	Convey("A locked event outside the task's start and deadline is infeasible", t, func() {
+		for _, pinned := range []string{
+			`{"title": "Newsletter", "start": "2015-02-17T15:00:00Z", "end": "2015-02-17T16:00:00Z", "status": "locked"}`,
+			`{"title": "Study", "start": "2015-02-16T15:00:00Z", "end": "2015-02-16T16:00:00Z", "status": "locked"}`,
+		} {
+			params := strings.Replace(in, `"pinnedEvents": []`, `"pinnedEvents": [`+pinned+`]`, 1)
+			_, err := computeSchedule([]byte(params))
+			So(err, ShouldHaveSameTypeAs, &SolveError{})
+			So(err.(*SolveError).Solution, ShouldEqual, INFEASIBLE)
+		}
+	})
+
+	Convey("Without any columns there's nothing to solve and the schedule is empty", t, func() {
+		noTasks := `{
+			"timeZone": "America/New_York",
+			"weeklyTaskBlocks": [[], [{"start": "10:00", "end": "14:00"}], [], [], [], [], []],
+			"tasks": [],
+			"startTaskSchedule": "2015-02-16T14:00:00Z",
+			"endTaskSchedule": "2015-02-18T14:00:00Z"
+		}`
+		lateStart := strings.Replace(noTasks, `"tasks": []`,
+			`"tasks": [{"title": "Study", "estimatedHours": 2, "reward": 3, "startOnOrAfter": "2015-02-19T14:00:00Z"}]`, 1)
+		for _, params := range []string{noTasks, lateStart} {
+			for solver := range solvers {
+				out, err := parseAndComputeSchedule([]byte(strings.Replace(params, `"tasks"`, `"solver": "`+solver+`", "tasks"`, 1)))
+				So(err, ShouldBeNil)
+				So(string(out), ShouldEqual, "[]")
+			}
+		}
+
+		tp, err := computeSchedule([]byte(strings.Replace(lateStart, `"tasks"`, `"detailed": true, "tasks"`, 1)))
+		So(err, ShouldBeNil)
+		So(tp.Unscheduled, ShouldResemble, []UnscheduledTask{{"Study", 2, 0, StartAfterEnd}})
+	})
+}
+
+// Three months of work days, with each task due two weeks after it can start
+func BenchmarkThreeMonthSchedule(b *testing.B) {
+	start := Date(2015, 3, 2, 14, 0, 0, 0, UTC)
+	week := 7 * 24 * Hour
+	workDay := []map[string]string{{"start": "9:00", "end": "12:00"}, {"start": "13:00", "end": "17:00"}}
+	tasks := make([]map[string]interface{}, 40)
+	for i := range tasks {
+		startWeek := Duration(i % 11)
+		tasks[i] = map[string]interface{}{
+			"title":          fmt.Sprintf("Task %d", i),
+			"estimatedHours": 4 + i%7,
+			"reward":         1 + i%13,
+			"startOnOrAfter": start.Add(startWeek * week).Format(RFC3339),
+			"deadline":       start.Add((startWeek + 2) * week).Format(RFC3339),
+		}
+	}
+	params, err := json.Marshal(map[string]interface{}{
+		"timeZone":          "America/New_York",
+		"weeklyTaskBlocks":  [][]map[string]string{{}, workDay, workDay, workDay, workDay, workDay, {}},
+		"tasks":             tasks,
+		"startTaskSchedule": start.Format(RFC3339),
+		"endTaskSchedule":   start.Add(13 * week).Format(RFC3339),
+	})
+	if err != nil {
+		b.Fatal(err)
+	}
+
+	b.ResetTimer()
+	for i := 0; i < b.N; i++ {
+		if _, err := computeSchedule(params); err != nil {
+			b.Fatal(err)
+		}
+	}
+}
+
+func TestInfeasibleDiagnosis(t *testing.T) {
+	in := []byte(`{
+		"timeZone": "America/New_York",
+		"diagnose": true,
+		"weeklyTaskBlocks": [
+			[],
+			[{"start": "10:00", "end": "14:00"}],
+			[{"start": "10:00", "end": "14:00"}],
+			[],
+			[],
+			[],
+			[]
+		],
+		"appointments": [	],
+		"tasks": [
+			{"title": "Study", "estimatedHours": 1, "reward": 15, "deadline": "2015-02-17T19:00:00Z"},
+			{"title": "Newsletter", "estimatedHours": 3, "reward": 9, "deadline": "2015-02-16T19:00:00Z"},
+			{"title": "Reimbursements", "estimatedHours": 1, "reward": 5},
+			{"title": "Admin", "estimatedHours": 2, "reward": 3, "deadline": "2015-02-16T18:00:00Z"}
+		],
+		"startTaskSchedule": "2015-02-16T14:00:00Z",
+		"endTaskSchedule": "2015-02-20T22:00:00Z"
+	}`)
+
+	Convey("When deadlines can't all be met, diagnose mode reports the minimal set of conflicting tasks", t, func() {
+		_, err := parseAndComputeSchedule(in)
+		So(err, ShouldNotBeNil)
+		solveErr, ok := err.(*SolveError)
+		So(ok, ShouldBeTrue)
+		So(solveErr.Solution, ShouldEqual, INFEASIBLE)
+		So(solveErr.Conflict, ShouldNotBeNil)
+		So(solveErr.Conflict.Tasks, ShouldResemble, []string{"Admin", "Newsletter"})
+		So(solveErr.Conflict.HoursNeeded, ShouldEqual, 5)
+		So(solveErr.Conflict.SlotsAvailable, ShouldEqual, 4)
+		So(err.Error(), ShouldEqual,
+			"Could not solve linear program: Admin (2h) and Newsletter (3h) need 5h before Feb 16 14:00 but only 4 slots exist")
+
+		resp := errResponse(err)
+		So(resp["solution"], ShouldEqual, "INFEASIBLE")
+	})
+
+	Convey("Without diagnose mode only the solution type is reported", t, func() {
+		var tp TaskParams
+		err := parseTaskParams(in, &tp)
+		So(err, ShouldBeNil)
+		tp.Diagnose = false
+		err = tp.calcSchedule()
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "Could not solve linear program")
+		So(errResponse(err)["solution"], ShouldEqual, "INFEASIBLE")
+	})
+}
+
+func TestSoftDeadlines(t *testing.T) {
+	in := []byte(`{
+		"timeZone": "America/New_York",
+		"weeklyTaskBlocks": [
+			[],
+			[{"start": "10:00", "end": "14:00"}],
+			[{"start": "10:00", "end": "14:00"}],
+			[],
+			[],
+			[],
+			[]
+		],
+		"appointments": [	],
+		"tasks": [
+			{"title": "Newsletter", "estimatedHours": 3, "reward": 9, "deadline": "2015-02-16T19:00:00Z",
+				"deadlineType": "soft", "latePenaltyPerHour": 10},
+			{"title": "Admin", "estimatedHours": 2, "reward": 3, "deadline": "2015-02-16T18:00:00Z"}
+		],
+		"startTaskSchedule": "2015-02-16T14:00:00Z",
+		"endTaskSchedule": "2015-02-20T22:00:00Z"
+	}`)
+
+	expectedOut := []byte(`[
+	    { "title": "Newsletter", "start": "2015-02-16T15:00:00Z", "end": "2015-02-16T16:00:00Z", "finish": false },
+	    { "title": "Admin", "start": "2015-02-16T16:00:00Z", "end": "2015-02-16T18:00:00Z", "finish": true },
+	    { "title": "Newsletter", "start": "2015-02-16T18:00:00Z", "end": "2015-02-16T19:00:00Z", "finish": false },
+	    { "title": "Newsletter", "start": "2015-02-17T15:00:00Z", "end": "2015-02-17T16:00:00Z", "finish": true,
+	      "late": true, "lateHours": 21 }
+	  ]`)
+
+	Convey("With a soft deadline that can't be met, it returns a best effort schedule marking the late task", t, func() {
+		actualOut, err := parseAndComputeSchedule(in)
+		So(err, ShouldBeNil)
+
+		var expectedParsed []interface{}
+		err = json.Unmarshal(expectedOut, &expectedParsed)
+		So(err, ShouldBeNil)
+
+		var actualParsed []interface{}
+		err = json.Unmarshal(actualOut, &actualParsed)
+		So(err, ShouldBeNil)
+
+		So(actualParsed, ShouldResemble, expectedParsed)
+	})
+}
+
+func TestBinary(t *testing.T) {
+	in := []byte(`{
+		"timeZone": "America/New_York",
+		"binary": true,
+		"weeklyTaskBlocks": [
+			[],
+			[{"start": "10:00", "end": "12:00"}],
+			[{"start": "9:00", "end": "10:00"}, {"start": "11:30", "end": "14:30"}],
+			[],
+			[],
+			[],
+			[]
+		],
+		"appointments": [	],
+		"tasks": [
+			{"title": "Newsletter", "estimatedHours": 2, "reward": 6},
+			{"title": "Reimbursements", "estimatedHours": 2, "reward": 6},
+			{"title": "Admin", "estimatedHours": 3, "reward": 9}
+		],
+		"startTaskSchedule": "2015-02-16T14:00:00Z",
+		"endTaskSchedule": "2015-02-18T22:00:00Z"
+	}`)
+
+	Convey("In binary mode, rewards aren't nudged and tied tasks are still scheduled in whole hours", t, func() {
+		var tp TaskParams
+		err := parseTaskParams(in, &tp)
+		So(err, ShouldBeNil)
+		So(tp.Tasks[0].Reward, ShouldEqual, 6)
+		So(tp.Tasks[1].Reward, ShouldEqual, 6)
+
+		err = tp.calcSchedule()
+		So(err, ShouldBeNil)
+		hoursByTitle := make(map[string]float64)
+		for _, event := range tp.TaskEvents {
+			hoursByTitle[event.Title] += event.End.Sub(event.Start).Hours()
+		}
+		So(hoursByTitle, ShouldResemble, map[string]float64{"Newsletter": 2, "Reimbursements": 2, "Admin": 2})
+	})
+}
+
+func TestUnscheduled(t *testing.T) {
+	in := []byte(`{
+		"timeZone": "America/New_York",
+		"detailed": true,
+		"weeklyTaskBlocks": [
+			[],
+			[{"start": "10:00", "end": "12:00"}],
+			[{"start": "9:00", "end": "10:00"}, {"start": "11:30", "end": "14:30"}],
+			[],
+			[],
+			[],
+			[]
+		],
+		"appointments": [	],
+		"tasks": [
+			{"title": "Newsletter", "estimatedHours": 2, "reward": 9},
+			{"title": "MPD", "estimatedHours": 7, "reward": 14},
+			{"title": "Send newsletter", "estimatedHours": 1, "reward": 1, "dependsOn": ["MPD"]},
+			{"title": "Study", "estimatedHours": 1, "reward": 15, "startOnOrAfter": "2015-02-18T15:00:00Z"}
+		],
+		"startTaskSchedule": "2015-02-16T14:00:00Z",
+		"endTaskSchedule": "2015-02-18T22:00:00Z"
+	}`)
+
+	expectedOut := []byte(`{
+		"events": [
+			{ "title": "Newsletter", "start": "2015-02-16T15:00:00Z", "end": "2015-02-16T17:00:00Z", "finish": true },
+			{ "title": "MPD", "start": "2015-02-17T14:00:00Z", "end": "2015-02-17T15:00:00Z", "finish": false },
+			{ "title": "MPD", "start": "2015-02-17T16:30:00Z", "end": "2015-02-17T19:30:00Z", "finish": false }
+		],
+		"unscheduled": [
+			{ "title": "MPD", "hoursRequested": 7, "hoursScheduled": 4, "reason": "horizonTooShort" },
+			{ "title": "Send newsletter", "hoursRequested": 1, "hoursScheduled": 0, "reason": "dependencyUnscheduled" },
+			{ "title": "Study", "hoursRequested": 1, "hoursScheduled": 0, "reason": "startAfterEnd" }
+		]
+	}`)
+
+	Convey("With a detailed response, tasks that couldn't be fully scheduled are listed with the reason", t, func() {
+		actualOut, err := parseAndComputeSchedule(in)
+		So(err, ShouldBeNil)
+
+		var expectedParsed map[string]interface{}
+		err = json.Unmarshal(expectedOut, &expectedParsed)
+		So(err, ShouldBeNil)
+
+		var actualParsed map[string]interface{}
+		err = json.Unmarshal(actualOut, &actualParsed)
+		So(err, ShouldBeNil)
+
+		So(actualParsed["events"], ShouldResemble, expectedParsed["events"])
+		So(actualParsed["unscheduled"], ShouldResemble, expectedParsed["unscheduled"])
+	})
+	Convey("When there are no work slots at all, every task is unscheduled", t, func() {
+		var tp TaskParams
+		err := parseTaskParams(in, &tp)
+		So(err, ShouldBeNil)
+		tp.EndTaskSchedule = tp.StartTaskSchedule
+		tp.calculateTaskHours()
+		So(tp.TaskHours, ShouldBeEmpty)
+
+		err = tp.calcSchedule()
+		So(err, ShouldBeNil)
+		So(tp.TaskEvents, ShouldBeEmpty)
+		So(len(tp.Unscheduled), ShouldEqual, 4)
+		for _, task := range tp.Unscheduled {
+			So(task.Reason, ShouldEqual, NoSlots)
+		}
+	})
+}
+
+func TestObjectiveBreakdown(t *testing.T) {
+	in := []byte(`{
+		"timeZone": "America/New_York",
+		"detailed": true,
+		"weeklyTaskBlocks": [
+			[],
+			[{"start": "10:00", "end": "13:00"}],
+			[],
+			[],
+			[],
+			[],
+			[]
+		],
+		"appointments": [	],
+		"tasks": [
+			{"title": "Newsletter", "estimatedHours": 2, "reward": 9},
+			{"title": "MPD", "estimatedHours": 2, "reward": 6},
+			{"title": "Study", "estimatedHours": 1, "reward": 1, "deadline": "2015-03-02T16:00:00Z",
+				"deadlineType": "soft", "latePenaltyPerHour": 1}
+		],
+		"startTaskSchedule": "2015-03-02T14:00:00Z",
+		"endTaskSchedule": "2015-03-03T14:00:00Z"
+	}`)
+
+	Convey("A detailed response has the objective value and each task's part of it", t, func() {
+		tp, err := computeSchedule(in)
+		So(err, ShouldBeNil)
+		resp := tp.scheduleResponse()
+
+		// Newsletter gets 10:00-12:00 and MPD 12:00-13:00, so Study is late and unscheduled
+		newsletterValue := 0.99 * 0.99 * 9 / 2 * (1 + 0.99)
+		mpdValue := 0.99 * 0.99 * 6 / 2 * 0.99 * 0.99
+		So(len(resp.Tasks), ShouldEqual, 3)
+		So(resp.Tasks[0].Title, ShouldEqual, "Newsletter")
+		So(resp.Tasks[0].HoursScheduled, ShouldEqual, 2)
+		So(resp.Tasks[0].RewardCaptured, ShouldEqual, 9)
+		So(resp.Tasks[0].WeightedValue, ShouldAlmostEqual, newsletterValue, 0.001)
+		So(resp.Tasks[1].HoursScheduled, ShouldEqual, 1)
+		So(resp.Tasks[1].RewardCaptured, ShouldEqual, 3)
+		So(resp.Tasks[1].WeightedValue, ShouldAlmostEqual, mpdValue, 0.001)
+		So(resp.Tasks[2].HoursScheduled, ShouldEqual, 0)
+		So(resp.Tasks[2].RewardCaptured, ShouldEqual, 0)
+		So(resp.Tasks[2].WeightedValue, ShouldEqual, -1)
+
+		So(resp.Objective, ShouldAlmostEqual, newsletterValue+mpdValue-1, 0.001)
+		So(resp.Objective, ShouldAlmostEqual, resp.Tasks[0].WeightedValue+resp.Tasks[1].WeightedValue+
+			resp.Tasks[2].WeightedValue, 0.000001)
+	})
+}
+
+func TestTaskProgress(t *testing.T) {
+	in := []byte(`{
+		"timeZone": "America/New_York",
+		"weeklyTaskBlocks": [
+			[],
+			[{"start": "10:00", "end": "13:00"}],
+			[{"start": "10:00", "end": "13:00"}],
+			[],
+			[],
+			[],
+			[]
+		],
+		"tasks": [
+			{"title": "Newsletter", "estimatedHours": 4, "hoursCompleted": 2, "reward": 9},
+			{"title": "MPD", "estimatedHours": 3, "hoursCompleted": 1, "remainingHours": 1, "reward": 6},
+			{"title": "Study", "estimatedHours": 2, "hoursCompleted": 3, "reward": 20}
+		],
+		"startTaskSchedule": "2015-03-02T14:00:00Z",
+		"endTaskSchedule": "2015-03-04T14:00:00Z"
+	}`)
+
+	Convey("Only the hours left on each task are scheduled", t, func() {
+		tp, err := computeSchedule(in)
+		So(err, ShouldBeNil)
+		So(tp.Tasks[0].EstimatedHours, ShouldEqual, 2)
+		So(tp.Tasks[1].EstimatedHours, ShouldEqual, 1)
+		So(tp.Tasks[2].EstimatedHours, ShouldEqual, 0)
+
+		out, err := parseAndComputeSchedule(in)
+		So(err, ShouldBeNil)
+		var actualParsed, expectedParsed []interface{}
+		So(json.Unmarshal(out, &actualParsed), ShouldBeNil)
+		So(json.Unmarshal([]byte(`[
+			{"title": "MPD", "start": "2015-03-02T15:00:00Z", "end": "2015-03-02T16:00:00Z", "finish": true},
+			{"title": "Newsletter", "start": "2015-03-02T16:00:00Z", "end": "2015-03-02T18:00:00Z", "finish": true}
+		]`), &expectedParsed), ShouldBeNil)
+		So(actualParsed, ShouldResemble, expectedParsed)
+	})
+
+	Convey("Negative progress is rejected", t, func() {
+		var tp TaskParams
+		err := parseTaskParams([]byte(strings.Replace(string(in), `"hoursCompleted": 2`, `"hoursCompleted": -2`, 1)), &tp)
+		So(err.Error(), ShouldEqual, "tasks[0].hoursCompleted can't be negative")
+		err = parseTaskParams([]byte(strings.Replace(string(in), `"remainingHours": 1`, `"remainingHours": -1`, 1)), &tp)
+		So(err.Error(), ShouldEqual, "tasks[1].remainingHours can't be negative")
+	})
+}
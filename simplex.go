@@ -0,0 +1,574 @@
+package main
+
+import (
+	"bytes"
+	"math"
+	"strconv"
+	. "time"
+)
+
+// A pure Go solver using the two phase simplex method on a dense tableau, with branch and bound for
+// the integer columns. It's slower than lp_solve on large schedules, but needs no C library, so the
+// service can be built statically or without cgo.
+type simplexSolver struct {
+	numCols     int
+	names       []string
+	lower       []float64
+	upper       []float64
+	isInt       []bool
+	constraints []simplexConstraint
+	objective   []float64
+	maximize    bool
+	variables   []float64
+	timeout     Duration
+	abort       func() bool
+	deadline    Time
+}
+
+type simplexConstraint struct {
+	row       []Entry
+	ct        ConstraintType
+	rightHand float64
+}
+
+const (
+	// Tolerance for treating a value as zero in the tableau
+	simplexEps = 1e-9
+	// Tolerance for a sum of artificial variables to count as feasible and a value as integer
+	simplexFeasibleEps = 1e-7
+	// Branch and bound nodes to explore before returning the best solution found so far
+	simplexMaxNodes = 20000
+	// Pivots in a row that don't improve the objective before switching to Bland's rule, which
+	// can't cycle
+	simplexMaxDegenerate = 50
+	// Pivots between checks for the timeout and abort function
+	simplexStopCheckPivots = 100
+)
+
+func newSimplexSolver(numCols int) Solver {
+	s := &simplexSolver{
+		numCols:   numCols,
+		names:     make([]string, numCols),
+		lower:     make([]float64, numCols),
+		upper:     make([]float64, numCols),
+		isInt:     make([]bool, numCols),
+		objective: make([]float64, numCols),
+	}
+	for col := range s.upper {
+		s.upper[col] = math.Inf(1)
+	}
+	return s
+}
+
+func (s *simplexSolver) SetColName(col int, name string) {
+	s.names[col] = name
+}
+
+func (s *simplexSolver) SetInt(col int, mustBeInt bool) {
+	s.isInt[col] = mustBeInt
+}
+
+func (s *simplexSolver) SetBinary(col int, mustBeBinary bool) {
+	s.isInt[col] = mustBeBinary
+	if mustBeBinary {
+		s.lower[col], s.upper[col] = 0, 1
+	}
+}
+
+func (s *simplexSolver) SetBounds(col int, lower, upper float64) {
+	s.lower[col], s.upper[col] = lower, upper
+}
+
+func (s *simplexSolver) AddConstraintSparse(row []Entry, ct ConstraintType, rightHand float64) error {
+	s.constraints = append(s.constraints, simplexConstraint{append([]Entry{}, row...), ct, rightHand})
+	return nil
+}
+
+func (s *simplexSolver) SetObjFn(row []float64, maximize bool) {
+	copy(s.objective, row)
+	s.maximize = maximize
+}
+
+func (s *simplexSolver) SetTimeout(seconds int) {
+	s.timeout = Duration(seconds) * Second
+}
+
+func (s *simplexSolver) SetAbortFunc(abort func() bool) {
+	s.abort = abort
+}
+
+// Whether to stop solving, and with which solution type, once the timeout passes or the abort
+// function returns true
+func (s *simplexSolver) stopped() (SolutionType, bool) {
+	if s.abort != nil && s.abort() {
+		return USERABORT, true
+	}
+	if !s.deadline.IsZero() && !Now().Before(s.deadline) {
+		return TIMEOUT, true
+	}
+	return NOTRUN, false
+}
+
+// When stopping early, as lp_solve does, the best integer solution found is returned as SUBOPTIMAL
+func (s *simplexSolver) stopEarly(ret SolutionType, best []float64) SolutionType {
+	if best == nil {
+		return ret
+	}
+	s.variables = best
+	return SUBOPTIMAL
+}
+
+func (s *simplexSolver) GetObjective() float64 {
+	objective := 0.0
+	for col, val := range s.GetVariables() {
+		objective += s.objective[col] * val
+	}
+	return objective
+}
+
+func (s *simplexSolver) GetVariables() []float64 {
+	if s.variables == nil {
+		return make([]float64, s.numCols)
+	}
+	return append([]float64{}, s.variables...)
+}
+
+// Branch and bound depth first on the most fractional integer column, starting with the branch
+// that rounds it up, as that's usually the one that schedules more of a task
+func (s *simplexSolver) Solve() SolutionType {
+	s.variables = nil
+	s.deadline = Time{}
+	if s.timeout > 0 {
+		s.deadline = Now().Add(s.timeout)
+	}
+	cost := make([]float64, s.numCols)
+	for col, val := range s.objective {
+		if s.maximize {
+			cost[col] = -val
+		} else {
+			cost[col] = val
+		}
+	}
+
+	type node struct{ lower, upper []float64 }
+	stack := []node{{s.lower, s.upper}}
+	var best []float64
+	bestCost := math.Inf(1)
+	for nodes := 0; len(stack) > 0; nodes++ {
+		if nodes == simplexMaxNodes {
+			return s.stopEarly(NOFEASFOUND, best)
+		}
+		if ret, stop := s.stopped(); stop {
+			return s.stopEarly(ret, best)
+		}
+		n := stack[len(stack)-1]
+		stack = stack[:len(stack)-1]
+
+		x, ret := s.solveRelaxation(cost, n.lower, n.upper)
+		if ret == TIMEOUT || ret == USERABORT {
+			return s.stopEarly(ret, best)
+		}
+		if ret == UNBOUNDED || ret == NUMFAILURE {
+			return ret
+		}
+		if ret != OPTIMAL {
+			continue
+		}
+		xCost := 0.0
+		for col, val := range x {
+			xCost += cost[col] * val
+		}
+		if xCost >= bestCost-simplexEps*math.Max(1, math.Abs(bestCost)) {
+			continue
+		}
+
+		branchCol, mostFractional := -1, simplexFeasibleEps
+		for col, val := range x {
+			if fractional := math.Abs(val - math.Floor(val+0.5)); s.isInt[col] && fractional > mostFractional {
+				branchCol, mostFractional = col, fractional
+			}
+		}
+		if branchCol < 0 {
+			best, bestCost = x, xCost
+			continue
+		}
+		down := node{n.lower, append([]float64{}, n.upper...)}
+		down.upper[branchCol] = math.Floor(x[branchCol])
+		up := node{append([]float64{}, n.lower...), n.upper}
+		up.lower[branchCol] = math.Ceil(x[branchCol])
+		stack = append(stack, down, up)
+	}
+
+	if best == nil {
+		return INFEASIBLE
+	}
+	s.variables = best
+	return OPTIMAL
+}
+
+// Solve the linear program without the integer restrictions, minimizing the cost, with the given
+// column bounds
+func (s *simplexSolver) solveRelaxation(cost, lower, upper []float64) ([]float64, SolutionType) {
+	// Fixed columns are left out, and the rest are shifted by their lower bound to be non-negative
+	vars := make([]int, s.numCols)
+	numVars := 0
+	for col := range vars {
+		if lower[col] > upper[col]+simplexEps {
+			return nil, INFEASIBLE
+		}
+		if upper[col]-lower[col] > simplexEps {
+			vars[col] = numVars
+			numVars++
+		} else {
+			vars[col] = -1
+		}
+	}
+
+	rows := make([]simplexConstraint, 0, len(s.constraints))
+	for _, c := range s.constraints {
+		row := make([]Entry, 0, len(c.row))
+		rightHand := c.rightHand
+		for _, entry := range c.row {
+			rightHand -= entry.Val * lower[entry.Col]
+			if vars[entry.Col] >= 0 && entry.Val != 0 {
+				row = append(row, Entry{vars[entry.Col], entry.Val})
+			}
+		}
+		if len(row) == 0 {
+			if (c.ct == LE && rightHand < -simplexFeasibleEps) || (c.ct == GE && rightHand > simplexFeasibleEps) ||
+				(c.ct == EQ && math.Abs(rightHand) > simplexFeasibleEps) {
+				return nil, INFEASIBLE
+			}
+			continue
+		}
+		rows = append(rows, simplexConstraint{row, c.ct, rightHand})
+	}
+	for col, v := range vars {
+		if v >= 0 && !math.IsInf(upper[col], 1) {
+			rows = append(rows, simplexConstraint{[]Entry{{v, 1}}, LE, upper[col] - lower[col]})
+		}
+	}
+
+	t := newTableau(numVars, rows)
+	t.stopped = s.stopped
+	if t.artStart < t.rhs {
+		if ret := t.minimizeArtificials(); ret != OPTIMAL {
+			return nil, ret
+		}
+	}
+	varCost := make([]float64, numVars)
+	for col, v := range vars {
+		if v >= 0 {
+			varCost[v] = cost[col]
+		}
+	}
+	if ret := t.minimize(varCost); ret != OPTIMAL {
+		return nil, ret
+	}
+
+	values := t.values()
+	x := make([]float64, s.numCols)
+	for col, v := range vars {
+		x[col] = lower[col]
+		if v >= 0 {
+			x[col] += values[v]
+		}
+		if s.isInt[col] && math.Abs(x[col]-math.Floor(x[col]+0.5)) <= simplexFeasibleEps {
+			x[col] = math.Floor(x[col] + 0.5)
+		}
+	}
+	return x, OPTIMAL
+}
+
+// A simplex tableau for constraints over non-negative variables. Its columns are the variables,
+// then the slack and surplus variables, then the artificial variables, then the right hand side.
+type tableau struct {
+	rows     [][]float64
+	obj      []float64
+	basis    []int
+	artStart int
+	rhs      int
+	stopped  func() (SolutionType, bool)
+}
+
+func newTableau(numVars int, constraints []simplexConstraint) *tableau {
+	numSlack, numArt := 0, 0
+	for i := range constraints {
+		c := &constraints[i]
+		// Make every right hand side non-negative so the starting basis is feasible
+		if c.rightHand < 0 {
+			for j := range c.row {
+				c.row[j].Val = -c.row[j].Val
+			}
+			c.rightHand = -c.rightHand
+			if c.ct == LE {
+				c.ct = GE
+			} else if c.ct == GE {
+				c.ct = LE
+			}
+		}
+		if c.ct != EQ {
+			numSlack++
+		}
+		if c.ct != LE {
+			numArt++
+		}
+	}
+
+	t := &tableau{
+		rows:     make([][]float64, len(constraints)),
+		basis:    make([]int, len(constraints)),
+		artStart: numVars + numSlack,
+		rhs:      numVars + numSlack + numArt,
+	}
+	slack, art := numVars, t.artStart
+	for i, c := range constraints {
+		row := make([]float64, t.rhs+1)
+		for _, entry := range c.row {
+			row[entry.Col] += entry.Val
+		}
+		row[t.rhs] = c.rightHand
+		switch c.ct {
+		case LE:
+			row[slack] = 1
+			t.basis[i] = slack
+			slack++
+		case GE:
+			row[slack] = -1
+			slack++
+			fallthrough
+		case EQ:
+			row[art] = 1
+			t.basis[i] = art
+			art++
+		}
+		t.rows[i] = row
+	}
+	return t
+}
+
+// Phase one: find a feasible basis by minimizing the sum of the artificial variables, then pivot
+// any left in the basis at zero out of it
+func (t *tableau) minimizeArtificials() SolutionType {
+	artCost := make([]float64, t.rhs)
+	for col := t.artStart; col < t.rhs; col++ {
+		artCost[col] = 1
+	}
+	if ret := t.run(artCost, t.rhs); ret != OPTIMAL {
+		return ret
+	}
+	if -t.obj[t.rhs] > simplexFeasibleEps {
+		return INFEASIBLE
+	}
+	for i, basic := range t.basis {
+		if basic < t.artStart {
+			continue
+		}
+		for col := 0; col < t.artStart; col++ {
+			if math.Abs(t.rows[i][col]) > simplexEps {
+				t.pivot(i, col)
+				break
+			}
+		}
+		// If there's no column to pivot on the constraint was redundant, and its artificial
+		// variable stays at zero as it can't leave the basis
+	}
+	return OPTIMAL
+}
+
+// Phase two: minimize the cost of the variables, without letting artificial variables back in
+func (t *tableau) minimize(varCost []float64) SolutionType {
+	cost := make([]float64, t.rhs)
+	copy(cost, varCost)
+	return t.run(cost, t.artStart)
+}
+
+// Run the simplex method on the cost, with only the first numEntering columns able to enter the
+// basis
+func (t *tableau) run(cost []float64, numEntering int) SolutionType {
+	t.obj = make([]float64, t.rhs+1)
+	copy(t.obj, cost)
+	for i, basic := range t.basis {
+		if cost[basic] != 0 {
+			for col, val := range t.rows[i] {
+				t.obj[col] -= cost[basic] * val
+			}
+		}
+	}
+
+	degenerate := 0
+	maxPivots := 50 * (len(t.rows) + t.rhs)
+	for pivots := 0; ; pivots++ {
+		if pivots > maxPivots {
+			return NUMFAILURE
+		}
+		if pivots%simplexStopCheckPivots == simplexStopCheckPivots-1 && t.stopped != nil {
+			if ret, stop := t.stopped(); stop {
+				return ret
+			}
+		}
+		enter := -1
+		mostNegative := -simplexEps
+		for col := 0; col < numEntering; col++ {
+			if t.obj[col] < mostNegative {
+				enter, mostNegative = col, t.obj[col]
+				if degenerate >= simplexMaxDegenerate {
+					break
+				}
+			}
+		}
+		if enter < 0 {
+			return OPTIMAL
+		}
+
+		leave := -1
+		minRatio := math.Inf(1)
+		for i, row := range t.rows {
+			if row[enter] <= simplexEps {
+				continue
+			}
+			ratio := row[t.rhs] / row[enter]
+			if ratio < minRatio-simplexEps || (leave >= 0 && ratio <= minRatio+simplexEps && t.basis[i] < t.basis[leave]) {
+				leave, minRatio = i, ratio
+			}
+		}
+		if leave < 0 {
+			return UNBOUNDED
+		}
+		if minRatio <= simplexEps {
+			degenerate++
+		} else {
+			degenerate = 0
+		}
+		t.pivot(leave, enter)
+	}
+}
+
+func (t *tableau) pivot(pivotRow, col int) {
+	row := t.rows[pivotRow]
+	scale := 1 / row[col]
+	nonZero := make([]int, 0)
+	for j, val := range row {
+		if val != 0 {
+			row[j] = val * scale
+			nonZero = append(nonZero, j)
+		}
+	}
+	row[col] = 1
+
+	eliminate := func(other []float64) {
+		factor := other[col]
+		if factor == 0 {
+			return
+		}
+		for _, j := range nonZero {
+			other[j] -= factor * row[j]
+			if math.Abs(other[j]) < simplexEps*simplexEps {
+				other[j] = 0
+			}
+		}
+		other[col] = 0
+	}
+	for i, other := range t.rows {
+		if i != pivotRow {
+			eliminate(other)
+		}
+	}
+	if t.obj != nil {
+		eliminate(t.obj)
+	}
+	t.basis[pivotRow] = col
+}
+
+// The value of each variable in the current basic solution
+func (t *tableau) values() []float64 {
+	values := make([]float64, t.rhs)
+	for i, basic := range t.basis {
+		values[basic] = t.rows[i][t.rhs]
+	}
+	return values
+}
+
+// Write the program in the LP format lp_solve writes
+func (s *simplexSolver) WriteToString() string {
+	var buf bytes.Buffer
+	if s.maximize {
+		buf.WriteString("/* Objective function */\nmax:")
+	} else {
+		buf.WriteString("/* Objective function */\nmin:")
+	}
+	for col, val := range s.objective {
+		if val != 0 {
+			buf.WriteString(" " + s.term(col, val))
+		}
+	}
+	buf.WriteString(";\n\n/* Constraints */\n")
+	for _, c := range s.constraints {
+		for i, entry := range c.row {
+			if i > 0 {
+				buf.WriteString(" ")
+			}
+			buf.WriteString(s.term(entry.Col, entry.Val))
+		}
+		buf.WriteString([]string{"", " <= ", " >= ", " = "}[c.ct] + formatLPNumber(c.rightHand) + ";\n")
+	}
+
+	bounds := make([]string, 0)
+	ints := make([]string, 0)
+	for col := 0; col < s.numCols; col++ {
+		if s.lower[col] == s.upper[col] {
+			bounds = append(bounds, s.colName(col)+" = "+formatLPNumber(s.lower[col])+";\n")
+			continue
+		}
+		if s.lower[col] != 0 {
+			bounds = append(bounds, s.colName(col)+" >= "+formatLPNumber(s.lower[col])+";\n")
+		}
+		if !math.IsInf(s.upper[col], 1) {
+			bounds = append(bounds, s.colName(col)+" <= "+formatLPNumber(s.upper[col])+";\n")
+		}
+		if s.isInt[col] {
+			ints = append(ints, s.colName(col))
+		}
+	}
+	if len(bounds) > 0 {
+		buf.WriteString("\n")
+		for _, bound := range bounds {
+			buf.WriteString(bound)
+		}
+	}
+	for i, name := range ints {
+		if i == 0 {
+			buf.WriteString("\nint " + name)
+		} else {
+			buf.WriteString("," + name)
+		}
+	}
+	if len(ints) > 0 {
+		buf.WriteString(";\n")
+	}
+	return buf.String()
+}
+
+func (s *simplexSolver) colName(col int) string {
+	if s.names[col] != "" {
+		return s.names[col]
+	}
+	return "C" + strconv.Itoa(col+1)
+}
+
+func (s *simplexSolver) term(col int, val float64) string {
+	switch val {
+	case 1:
+		return "+" + s.colName(col)
+	case -1:
+		return "-" + s.colName(col)
+	}
+	if val > 0 {
+		return "+" + formatLPNumber(val) + " " + s.colName(col)
+	}
+	return formatLPNumber(val) + " " + s.colName(col)
+}
+
+func formatLPNumber(val float64) string {
+	return strconv.FormatFloat(val, 'g', 12, 64)
+}
@@ -0,0 +1,124 @@
+package main
+
+import (
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// A mixed integer linear program as built by setupLP and read back by interpretTaskSchedule. Columns
+// are numbered from 0 and are non-negative unless given other bounds.
+type Solver interface {
+	SetColName(col int, name string)
+	SetInt(col int, mustBeInt bool)
+	SetBinary(col int, mustBeBinary bool)
+	SetBounds(col int, lower, upper float64)
+	AddConstraintSparse(row []Entry, ct ConstraintType, rightHand float64) error
+	SetObjFn(row []float64, maximize bool)
+	// Stop solving after the number of seconds, with TIMEOUT, or SUBOPTIMAL if an integer solution
+	// was found by then. Zero means no timeout.
+	SetTimeout(seconds int)
+	// A function called every so often while solving, which stops it with USERABORT by returning true
+	SetAbortFunc(abort func() bool)
+	Solve() SolutionType
+	GetObjective() float64
+	GetVariables() []float64
+	// The program in lp_solve's LP format
+	WriteToString() string
+}
+
+type Entry struct {
+	Col int
+	Val float64
+}
+
+type ConstraintType int
+
+const (
+	_ ConstraintType = iota
+	LE
+	GE
+	EQ
+)
+
+type SolutionType int
+
+// Values match the solve() return codes of lp_solve, so its results can be passed straight through
+const (
+	NOMEMORY    SolutionType = -2
+	NOTRUN      SolutionType = -1
+	OPTIMAL     SolutionType = 0
+	SUBOPTIMAL  SolutionType = 1
+	INFEASIBLE  SolutionType = 2
+	UNBOUNDED   SolutionType = 3
+	DEGENERATE  SolutionType = 4
+	NUMFAILURE  SolutionType = 5
+	USERABORT   SolutionType = 6
+	TIMEOUT     SolutionType = 7
+	PRESOLVED   SolutionType = 9
+	PROCFAIL    SolutionType = 10
+	PROCBREAK   SolutionType = 11
+	FEASFOUND   SolutionType = 12
+	NOFEASFOUND SolutionType = 13
+)
+
+var solutionTypeNames = map[SolutionType]string{
+	NOMEMORY:    "NOMEMORY",
+	NOTRUN:      "NOTRUN",
+	OPTIMAL:     "OPTIMAL",
+	SUBOPTIMAL:  "SUBOPTIMAL",
+	INFEASIBLE:  "INFEASIBLE",
+	UNBOUNDED:   "UNBOUNDED",
+	DEGENERATE:  "DEGENERATE",
+	NUMFAILURE:  "NUMFAILURE",
+	USERABORT:   "USERABORT",
+	TIMEOUT:     "TIMEOUT",
+	PRESOLVED:   "PRESOLVED",
+	PROCFAIL:    "PROCFAIL",
+	PROCBREAK:   "PROCBREAK",
+	FEASFOUND:   "FEASFOUND",
+	NOFEASFOUND: "NOFEASFOUND",
+}
+
+// Whether the solver found a schedule, which for SUBOPTIMAL and FEASFOUND is the best one found before
+// it stopped, e.g. for a timeout
+func (s SolutionType) hasSchedule() bool {
+	return s == OPTIMAL || s == SUBOPTIMAL || s == FEASFOUND
+}
+
+func (s SolutionType) String() string {
+	if name, ok := solutionTypeNames[s]; ok {
+		return name
+	}
+	return "SolutionType(" + strconv.Itoa(int(s)) + ")"
+}
+
+// Names for the solver option of a request
+const (
+	LPSolveSolver = "lpsolve"
+	SimplexSolver = "simplex"
+)
+
+// The solvers built in, by name. lp_solve is only built in with cgo and without the purego build
+// tag, in which case it's the default.
+var solvers = map[string]func(numCols int) Solver{
+	SimplexSolver: newSimplexSolver,
+}
+
+var DefaultSolver = SimplexSolver
+
+func solverNames() string {
+	names := make([]string, 0, len(solvers))
+	for name := range solvers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return strings.Join(names, ", ")
+}
+
+func newSolver(name string, numCols int) Solver {
+	if name == "" {
+		name = DefaultSolver
+	}
+	return solvers[name](numCols)
+}
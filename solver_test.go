@@ -0,0 +1,270 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestSimplexSolver(t *testing.T) {
+	Convey("It solves a linear program", t, func() {
+		// max 3x + 2y, x + y <= 4, x + 3y <= 6, x <= 3 is optimal at x = 3, y = 1
+		lp := newSimplexSolver(2)
+		lp.AddConstraintSparse([]Entry{{0, 1}, {1, 1}}, LE, 4)
+		lp.AddConstraintSparse([]Entry{{0, 1}, {1, 3}}, LE, 6)
+		lp.SetBounds(0, 0, 3)
+		lp.SetObjFn([]float64{3, 2}, true)
+		So(lp.Solve(), ShouldEqual, OPTIMAL)
+		So(lp.GetVariables()[0], ShouldAlmostEqual, 3)
+		So(lp.GetVariables()[1], ShouldAlmostEqual, 1)
+		So(lp.GetObjective(), ShouldAlmostEqual, 11)
+	})
+
+	Convey("It handles equality and greater than constraints", t, func() {
+		// min x + 2y, x + y = 5, x - y >= 1, y >= 1 is optimal at x = 4, y = 1
+		lp := newSimplexSolver(2)
+		lp.AddConstraintSparse([]Entry{{0, 1}, {1, 1}}, EQ, 5)
+		lp.AddConstraintSparse([]Entry{{0, 1}, {1, -1}}, GE, 1)
+		lp.AddConstraintSparse([]Entry{{1, 1}}, GE, 1)
+		lp.SetObjFn([]float64{1, 2}, false)
+		So(lp.Solve(), ShouldEqual, OPTIMAL)
+		So(lp.GetVariables()[0], ShouldAlmostEqual, 4)
+		So(lp.GetVariables()[1], ShouldAlmostEqual, 1)
+	})
+
+	Convey("It branches on integer columns", t, func() {
+		// max x + y, 2x + 2y <= 3 has the relaxed optimum 1.5 but the integer one 1
+		lp := newSimplexSolver(2)
+		lp.AddConstraintSparse([]Entry{{0, 2}, {1, 2}}, LE, 3)
+		lp.SetInt(0, true)
+		lp.SetInt(1, true)
+		lp.SetObjFn([]float64{1, 1.01}, true)
+		So(lp.Solve(), ShouldEqual, OPTIMAL)
+		So(lp.GetVariables(), ShouldResemble, []float64{0, 1})
+	})
+
+	Convey("It reports infeasible and unbounded programs", t, func() {
+		lp := newSimplexSolver(1)
+		lp.AddConstraintSparse([]Entry{{0, 1}}, GE, 2)
+		lp.SetBounds(0, 0, 1)
+		lp.SetObjFn([]float64{1}, true)
+		So(lp.Solve(), ShouldEqual, INFEASIBLE)
+
+		lp = newSimplexSolver(1)
+		lp.SetObjFn([]float64{1}, true)
+		So(lp.Solve(), ShouldEqual, UNBOUNDED)
+	})
+
+	Convey("It stops when the abort function returns true", t, func() {
+		lp := newSimplexSolver(2)
+		lp.AddConstraintSparse([]Entry{{0, 2}, {1, 2}}, LE, 3)
+		lp.SetInt(0, true)
+		lp.SetInt(1, true)
+		lp.SetObjFn([]float64{1, 1.01}, true)
+		lp.SetTimeout(60)
+		lp.SetAbortFunc(func() bool { return true })
+		So(lp.Solve(), ShouldEqual, USERABORT)
+
+		lp.SetAbortFunc(nil)
+		So(lp.Solve(), ShouldEqual, OPTIMAL)
+	})
+
+	Convey("It returns the best integer solution found as SUBOPTIMAL when stopped early", t, func() {
+		// max 2x + y, x + y <= 1.5 for binary x and y is optimal at x = 1, y = 0, but rounding y up
+		// first finds x = 0, y = 1 at the fourth node, before the fifth for y = 0
+		lp := newSimplexSolver(2)
+		lp.AddConstraintSparse([]Entry{{0, 1}, {1, 1}}, LE, 1.5)
+		lp.SetBinary(0, true)
+		lp.SetBinary(1, true)
+		lp.SetObjFn([]float64{2, 1}, true)
+		nodes := 0
+		lp.SetAbortFunc(func() bool {
+			nodes++
+			return nodes == 5
+		})
+		So(lp.Solve(), ShouldEqual, SUBOPTIMAL)
+		So(lp.GetVariables(), ShouldResemble, []float64{0, 1})
+	})
+
+	Convey("It writes the program in LP format", t, func() {
+		lp := newSimplexSolver(2)
+		lp.SetColName(0, "x")
+		lp.AddConstraintSparse([]Entry{{0, 1}, {1, -2.5}}, LE, 4)
+		lp.SetBinary(0, true)
+		lp.SetObjFn([]float64{3, 1}, true)
+		So(lp.WriteToString(), ShouldEqual, "/* Objective function */\nmax: +3 x +C2;\n\n/* Constraints */\n"+
+			"+x -2.5 C2 <= 4;\n\nx <= 1;\n\nint x;\n")
+	})
+}
+
+func TestSolverOption(t *testing.T) {
+	in := `{
+		"timeZone": "America/New_York",
+		"solver": "simplex",
+		"weeklyTaskBlocks": [
+			[],
+			[{"start": "9:00", "end": "12:00"}, {"start": "13:00", "end": "17:00"}],
+			[{"start": "9:00", "end": "12:00"}, {"start": "13:00", "end": "17:00"}],
+			[],
+			[],
+			[],
+			[]
+		],
+		"appointments": [{"title": "Meeting", "start": "2015-03-02T19:00:00Z", "end": "2015-03-02T20:00:00Z"}],
+		"tasks": [
+			{"id": "draft", "title": "Draft", "estimatedHours": 3, "reward": 6, "minChunkHours": 2},
+			{"title": "Send", "estimatedHours": 1, "reward": 10, "dependsOn": ["draft"]},
+			{"title": "Budget", "estimatedHours": 2, "reward": 8, "deadline": "2015-03-02T16:00:00Z"},
+			{"title": "Review", "estimatedHours": 4, "reward": 5, "maxHoursPerDay": 2,
+				"deadline": "2015-03-03T15:00:00Z", "deadlineType": "soft", "latePenaltyPerHour": 1},
+			{"title": "Filing", "estimatedHours": 2, "reward": 1, "allowedBlocks": [{"start": "13:00", "end": "17:00"}]}
+		],
+		"startTaskSchedule": "2015-03-02T14:00:00Z",
+		"endTaskSchedule": "2015-03-04T14:00:00Z"
+	}`
+
+	Convey("The pure Go solver gives the same schedule as lp_solve", t, func() {
+		if _, ok := solvers[LPSolveSolver]; !ok {
+			SkipSo("lp_solve isn't built in")
+			return
+		}
+		for _, binary := range []string{"false", "true"} {
+			params := strings.Replace(in, `"solver": "simplex",`, `"solver": "simplex", "binary": `+binary+`,`, 1)
+			simplexOut, err := parseAndComputeSchedule([]byte(params))
+			So(err, ShouldBeNil)
+			lpSolveOut, err := parseAndComputeSchedule([]byte(strings.Replace(params, `"simplex"`, `"lpsolve"`, 1)))
+			So(err, ShouldBeNil)
+
+			var simplexParsed, lpSolveParsed []interface{}
+			So(json.Unmarshal(simplexOut, &simplexParsed), ShouldBeNil)
+			So(json.Unmarshal(lpSolveOut, &lpSolveParsed), ShouldBeNil)
+			So(simplexParsed, ShouldNotBeEmpty)
+			So(simplexParsed, ShouldResemble, lpSolveParsed)
+		}
+	})
+
+	Convey("An infeasible schedule is reported the same way", t, func() {
+		params := strings.Replace(in, `"estimatedHours": 2, "reward": 8`, `"estimatedHours": 4, "reward": 8`, 1)
+		_, err := computeSchedule([]byte(params))
+		So(err, ShouldHaveSameTypeAs, &SolveError{})
+		So(err.(*SolveError).Solution, ShouldEqual, INFEASIBLE)
+	})
+
+	Convey("An unknown solver is rejected", t, func() {
+		var tp TaskParams
+		err := parseTaskParams([]byte(strings.Replace(in, `"simplex"`, `"cplex"`, 1)), &tp)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldStartWith, "solver must be one of: ")
+	})
+}
+
+// Solves optimally but reports that it stopped early, as lp_solve does after a timeout with an
+// integer solution found
+type stoppedSolver struct {
+	Solver
+	solution SolutionType
+	timeout  *int
+}
+
+func (s stoppedSolver) SetTimeout(seconds int) {
+	*s.timeout = seconds
+	s.Solver.SetTimeout(seconds)
+}
+
+func (s stoppedSolver) Solve() SolutionType {
+	if ret := s.Solver.Solve(); ret != OPTIMAL {
+		return ret
+	}
+	return s.solution
+}
+
+func TestSolverTimeout(t *testing.T) {
+	in := `{
+		"timeZone": "America/New_York",
+		"solver": "stopped",
+		"solverTimeoutSeconds": 30,
+		"weeklyTaskBlocks": [
+			[],
+			[{"start": "9:00", "end": "12:00"}],
+			[{"start": "9:00", "end": "12:00"}],
+			[],
+			[],
+			[],
+			[]
+		],
+		"tasks": [
+			{"title": "Draft", "estimatedHours": 3, "reward": 6},
+			{"title": "Budget", "estimatedHours": 2, "reward": 8}
+		],
+		"startTaskSchedule": "2015-03-02T14:00:00Z",
+		"endTaskSchedule": "2015-03-04T14:00:00Z"
+	}`
+	solution, timeout := SUBOPTIMAL, 0
+	solvers["stopped"] = func(numCols int) Solver {
+		return stoppedSolver{newSimplexSolver(numCols), solution, &timeout}
+	}
+	defer delete(solvers, "stopped")
+
+	Convey("The solver is given the timeout", t, func() {
+		solution = OPTIMAL
+		_, err := computeSchedule([]byte(in))
+		So(err, ShouldBeNil)
+		So(timeout, ShouldEqual, 30)
+	})
+
+	Convey("The best schedule found is returned flagged as not optimal", t, func() {
+		for _, solution = range []SolutionType{SUBOPTIMAL, FEASFOUND} {
+			r := httptest.NewRequest("POST", "/", strings.NewReader(in))
+			w := httptest.NewRecorder()
+			computeScheduleHandler(w, r)
+			So(w.Code, ShouldEqual, http.StatusOK)
+			So(w.Header().Get(optimalHeader), ShouldEqual, "false")
+			var events []TaskEvent
+			So(json.Unmarshal(w.Body.Bytes(), &events), ShouldBeNil)
+			So(len(events), ShouldEqual, 3)
+
+			out, err := parseAndComputeSchedule([]byte(strings.Replace(in, `"solver"`, `"detailed": true, "solver"`, 1)))
+			So(err, ShouldBeNil)
+			var resp map[string]interface{}
+			So(json.Unmarshal(out, &resp), ShouldBeNil)
+			So(resp["optimal"], ShouldEqual, false)
+			So(len(resp["events"].([]interface{})), ShouldEqual, 3)
+
+			var stdout, stderr strings.Builder
+			So(runCLI([]string{"compute", "-format", "table"}, strings.NewReader(in), &stdout, &stderr), ShouldEqual, 0)
+			So(stdout.String(), ShouldEndWith, "Not optimal: the solver stopped with the best schedule it had found\n")
+
+			stdout.Reset()
+			So(runCLI([]string{"compute"}, strings.NewReader(in), &stdout, &stderr), ShouldEqual, 0)
+			So(json.Unmarshal([]byte(stdout.String()), &events), ShouldBeNil)
+			So(stderr.String(), ShouldEqual, "Not optimal: the solver stopped with the best schedule it had found\n")
+		}
+	})
+
+	Convey("An optimal detailed schedule is flagged as optimal", t, func() {
+		solution = OPTIMAL
+		out, err := parseAndComputeSchedule([]byte(strings.Replace(in, `"solver"`, `"detailed": true, "solver"`, 1)))
+		So(err, ShouldBeNil)
+		var resp map[string]interface{}
+		So(json.Unmarshal(out, &resp), ShouldBeNil)
+		So(resp["optimal"], ShouldEqual, true)
+	})
+
+	Convey("A timeout without a schedule is an error", t, func() {
+		solution = TIMEOUT
+		_, err := computeSchedule([]byte(in))
+		So(err, ShouldHaveSameTypeAs, &SolveError{})
+		So(err.(*SolveError).Solution, ShouldEqual, TIMEOUT)
+	})
+
+	Convey("The timeout can't be negative", t, func() {
+		var tp TaskParams
+		err := parseTaskParams([]byte(strings.Replace(in, `"solverTimeoutSeconds": 30`, `"solverTimeoutSeconds": -1`, 1)), &tp)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldEqual, "solverTimeoutSeconds can't be negative")
+	})
+}
@@ -0,0 +1,319 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"reflect"
+	"strconv"
+	"strings"
+	. "time"
+)
+
+// A problem with one field of a request, with the path of the field in the request JSON, e.g.
+// "tasks[2].estimatedHours"
+type FieldError struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// Every problem found in a request, which is returned as a 400 Bad Request
+type ValidationError struct {
+	Errors []FieldError `json:"errors"`
+}
+
+func (err *ValidationError) Error() string {
+	messages := make([]string, len(err.Errors))
+	for i, fieldErr := range err.Errors {
+		messages[i] = strings.TrimSpace(fieldErr.Field + " " + fieldErr.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (err *ValidationError) add(field, message string) {
+	err.Errors = append(err.Errors, FieldError{Field: field, Message: message})
+}
+
+// A validation error for a problem only found once the request has been partly resolved, like a
+// dependsOn that matches no task once recurring tasks are expanded
+func fieldError(field, message string) error {
+	return &ValidationError{Errors: []FieldError{{Field: field, Message: message}}}
+}
+
+// Turn an error decoding the request JSON into a validation error, with the field it's in if known
+func jsonValidationError(err error, data []byte) error {
+	validationErr := &ValidationError{}
+	if syntaxErr, ok := err.(*json.SyntaxError); ok {
+		validationErr.add("", fmt.Sprintf("invalid JSON at offset %d: %s", syntaxErr.Offset, syntaxErr.Error()))
+		return validationErr
+	}
+
+	field, fieldType := "", reflect.Type(nil)
+	if typeErr, ok := err.(*json.UnmarshalTypeError); ok && typeErr.Field != "" {
+		// The decoder gives the path with Go field names, e.g. Tasks.2.EstimatedHours
+		for _, name := range strings.Split(typeErr.Field, ".") {
+			if _, err := strconv.Atoi(name); err == nil {
+				field += "[" + name + "]"
+			} else if name != "" {
+				field = joinFieldPath(field, lowerFirst(name))
+			}
+		}
+	} else if path, t, fieldErr := unmarshalErrorField(data, reflect.TypeOf(TaskParams{})); fieldErr != nil {
+		// Errors from a type's own UnmarshalJSON come without the field they're in
+		field, fieldType, err = path, t, fieldErr
+	}
+
+	if typeErr, ok := err.(*json.UnmarshalTypeError); ok {
+		validationErr.add(field, "can't be a "+typeErr.Value)
+	} else if fieldType == reflect.TypeOf(Time{}) {
+		validationErr.add(field, `must be a time formatted as RFC 3339, e.g. "2015-02-16T14:00:00Z"`)
+	} else if fieldType == reflect.TypeOf(TimeWithoutDate{}) {
+		validationErr.add(field, `must be a time of day formatted as "15:04"`)
+	} else {
+		validationErr.add(field, err.Error())
+	}
+	return validationErr
+}
+
+var unmarshalerType = reflect.TypeOf((*json.Unmarshaler)(nil)).Elem()
+
+// Find the first value in the JSON that its own UnmarshalJSON rejects, by decoding the JSON again a
+// value at a time, and return its path and type along with the error
+func unmarshalErrorField(data []byte, t reflect.Type) (string, reflect.Type, error) {
+	if reflect.PointerTo(t).Implements(unmarshalerType) {
+		return "", t, json.Unmarshal(data, reflect.New(t).Interface())
+	}
+	switch t.Kind() {
+	case reflect.Ptr:
+		return unmarshalErrorField(data, t.Elem())
+	case reflect.Struct:
+		var values map[string]json.RawMessage
+		if json.Unmarshal(data, &values) != nil {
+			return "", nil, nil
+		}
+		for i := 0; i < t.NumField(); i++ {
+			name := jsonFieldName(t.Field(i))
+			for key, value := range values {
+				if name == "" || !strings.EqualFold(key, name) {
+					continue
+				}
+				if path, fieldType, err := unmarshalErrorField(value, t.Field(i).Type); err != nil {
+					return joinFieldPath(name, path), fieldType, err
+				}
+			}
+		}
+	case reflect.Slice, reflect.Array:
+		var values []json.RawMessage
+		if json.Unmarshal(data, &values) != nil {
+			return "", nil, nil
+		}
+		for i, value := range values {
+			if path, fieldType, err := unmarshalErrorField(value, t.Elem()); err != nil {
+				return joinFieldPath(fmt.Sprintf("[%d]", i), path), fieldType, err
+			}
+		}
+	}
+	return "", nil, nil
+}
+
+// The name of a struct field in the request JSON, or "" for fields that aren't in it
+func jsonFieldName(field reflect.StructField) string {
+	if field.PkgPath != "" || field.Anonymous {
+		return ""
+	}
+	name := strings.Split(field.Tag.Get("json"), ",")[0]
+	if name == "-" {
+		return ""
+	} else if name == "" {
+		name = lowerFirst(field.Name)
+	}
+	return name
+}
+
+func joinFieldPath(parent, child string) string {
+	if parent == "" || child == "" || strings.HasPrefix(child, "[") {
+		return parent + child
+	}
+	return parent + "." + child
+}
+
+func lowerFirst(name string) string {
+	return strings.ToLower(name[:1]) + name[1:]
+}
+
+// Check the request for problems before using it, so that every problem can be reported at once
+// rather than the first one found or a panic part way through
+func (tp TaskParams) validate() error {
+	validationErr := &ValidationError{}
+	loc, locErr := LoadLocation(tp.TimeZoneName)
+	if locErr != nil {
+		validationErr.add("timeZone", "is not a known time zone: "+tp.TimeZoneName)
+	}
+	if tp.SlotMinutes < 0 || (tp.SlotMinutes > 0 && 60%tp.SlotMinutes != 0) {
+		validationErr.add("slotMinutes", "must evenly divide an hour, e.g. 15, 30 or 60")
+	}
+	if _, ok := solvers[tp.SolverName]; tp.SolverName != "" && !ok {
+		validationErr.add("solver", "must be one of: "+solverNames())
+	}
+	if tp.SolverTimeoutSeconds < 0 {
+		validationErr.add("solverTimeoutSeconds", "can't be negative")
+	}
+	if tp.Engine != "" && tp.Engine != LPEngine && tp.Engine != FlowEngine {
+		validationErr.add("engine", `must be "lp" or "flow"`)
+	}
+	if !tp.EndTaskSchedule.After(tp.StartTaskSchedule) {
+		validationErr.add("endTaskSchedule", "must be after startTaskSchedule")
+	}
+	if len(tp.People) == 0 {
+		validationErr.checkWeeklyTaskBlocks("weeklyTaskBlocks", tp.WeeklyTaskBlocks)
+	}
+	validationErr.checkAppointments("appointments", tp.Appointments)
+	validationErr.checkObjective(tp.Objective)
+
+	names := make(map[string]bool)
+	for i, person := range tp.People {
+		field := fmt.Sprintf("people[%d]", i)
+		if person.Name == "" {
+			validationErr.add(field+".name", "is required")
+		} else if names[person.Name] {
+			validationErr.add(field+".name", "is the same as another person's: "+person.Name)
+		}
+		names[person.Name] = true
+		if _, err := LoadLocation(person.TimeZoneName); err != nil {
+			validationErr.add(field+".timeZone", "is not a known time zone: "+person.TimeZoneName)
+		}
+		validationErr.checkWeeklyTaskBlocks(field+".weeklyTaskBlocks", person.WeeklyTaskBlocks)
+		validationErr.checkAppointments(field+".appointments", person.Appointments)
+	}
+
+	for i, task := range tp.Tasks {
+		field := fmt.Sprintf("tasks[%d]", i)
+		if task.RemainingHours == nil && task.EstimatedHours <= 0 {
+			validationErr.add(field+".estimatedHours", "must be positive")
+		}
+		if task.HoursCompleted < 0 {
+			validationErr.add(field+".hoursCompleted", "can't be negative")
+		}
+		if task.RemainingHours != nil && *task.RemainingHours < 0 {
+			validationErr.add(field+".remainingHours", "can't be negative")
+		}
+		if !task.Deadline.IsZero() && !task.StartOnOrAfter.IsZero() && !task.Deadline.After(task.StartOnOrAfter) {
+			validationErr.add(field+".deadline", "must be after startOnOrAfter")
+		}
+		switch task.DeadlineType {
+		case "", HardDeadline, SoftDeadline:
+		default:
+			validationErr.add(field+".deadlineType", `must be "hard" or "soft"`)
+		}
+		if task.Recurrence != nil && locErr == nil {
+			validationErr.checkRecurrence(field, task, loc)
+		}
+		for j, block := range task.AllowedBlocks {
+			validationErr.checkTaskBlock(fmt.Sprintf("%s.allowedBlocks[%d]", field, j), block)
+		}
+		for j, block := range task.PreferredBlocks {
+			validationErr.checkTaskBlock(fmt.Sprintf("%s.preferredBlocks[%d]", field, j), block)
+		}
+		if len(task.People) > 0 && len(tp.People) == 0 {
+			validationErr.add(field+".people", "can't be given without the request's people")
+		} else {
+			for j, name := range task.People {
+				if !names[name] {
+					validationErr.add(fmt.Sprintf("%s.people[%d]", field, j), "is not one of the people: "+name)
+				}
+			}
+		}
+	}
+
+	for i, pinned := range tp.PinnedEvents {
+		field := fmt.Sprintf("pinnedEvents[%d]", i)
+		if !pinned.End.After(pinned.Start) {
+			validationErr.add(field+".end", "must be after start")
+		}
+		switch pinned.Status {
+		case "", PinnedLocked, PinnedDone, PinnedPlanned:
+		default:
+			validationErr.add(field+".status", `must be "locked", "done" or "planned"`)
+		}
+		if pinned.Assignee != "" && len(tp.People) > 0 && !names[pinned.Assignee] {
+			validationErr.add(field+".assignee", "is not one of the people: "+pinned.Assignee)
+		}
+	}
+
+	if len(validationErr.Errors) > 0 {
+		return validationErr
+	}
+	return nil
+}
+
+func (err *ValidationError) checkWeeklyTaskBlocks(field string, days [][]TimeBlock) {
+	if len(days) != 7 {
+		err.add(field, "must have 7 days, from Sunday to Saturday")
+		return
+	}
+	for day, blocks := range days {
+		for i, block := range blocks {
+			err.checkBlock(fmt.Sprintf("%s[%d][%d]", field, day, i), block.Start, block.End)
+		}
+	}
+}
+
+func (err *ValidationError) checkBlock(field string, start, end TimeWithoutDate) {
+	if !end.After(start.Time) {
+		err.add(field+".end", "must be after start")
+	}
+}
+
+func (err *ValidationError) checkTaskBlock(field string, block TaskBlock) {
+	for i, day := range block.Days {
+		if _, parseErr := parseWeekday(day); parseErr != nil {
+			err.add(fmt.Sprintf("%s.days[%d]", field, i), "is not a weekday: "+day)
+		}
+	}
+	err.checkBlock(field, block.Start, block.End)
+}
+
+func (err *ValidationError) checkRecurrence(field string, task Task, loc *Location) {
+	if task.HoursCompleted != 0 {
+		err.add(field+".hoursCompleted", "can't be given for a recurring task")
+	}
+	if task.RemainingHours != nil {
+		err.add(field+".remainingHours", "can't be given for a recurring task")
+	}
+	if !task.Deadline.IsZero() {
+		err.add(field+".deadline", "can't be given for a recurring task, use recurrence.deadlineHours instead")
+	}
+	if task.Recurrence.DeadlineHours < 0 {
+		err.add(field+".recurrence.deadlineHours", "can't be negative")
+	}
+	if _, ruleErr := task.Recurrence.rule(loc); ruleErr != nil {
+		err.add(field+".recurrence", "is invalid: "+ruleErr.Error())
+	}
+}
+
+func (err *ValidationError) checkObjective(obj ObjectiveParams) {
+	if obj.DecayRate < 0 || obj.DecayRate > 1 {
+		err.add("objective.decayRate", "must be greater than 0 and at most 1")
+	}
+	if obj.UrgencyBonus < 0 {
+		err.add("objective.urgencyBonus", "can't be negative")
+	}
+	if obj.UrgencyWindowHours < 0 {
+		err.add("objective.urgencyWindowHours", "can't be negative")
+	} else if obj.UrgencyBonus > 0 && obj.UrgencyWindowHours == 0 {
+		err.add("objective.urgencyWindowHours", "is needed with an objective.urgencyBonus")
+	}
+	if obj.PreferredBlockBonus < 0 {
+		err.add("objective.preferredBlockBonus", "can't be negative")
+	}
+	if obj.StabilityPenalty < 0 {
+		err.add("objective.stabilityPenalty", "can't be negative")
+	}
+}
+
+func (err *ValidationError) checkAppointments(field string, appts []Appointment) {
+	for i, appt := range appts {
+		if !appt.End.After(appt.Start) {
+			err.add(fmt.Sprintf("%s[%d].end", field, i), "must be after start")
+		}
+	}
+}
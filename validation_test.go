@@ -0,0 +1,127 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	. "github.com/smartystreets/goconvey/convey"
+)
+
+func TestValidation(t *testing.T) {
+	in := `{
+		"timeZone": "America/Nowhere",
+		"weeklyTaskBlocks": [
+			[],
+			[{"start": "10:00", "end": "12:00"}, {"start": "14:00", "end": "13:00"}],
+			[]
+		],
+		"appointments": [{"title": "Meeting", "start": "2015-02-16T16:00:00Z", "end": "2015-02-16T16:00:00Z"}],
+		"tasks": [
+			{"title": "Newsletter", "estimatedHours": 3, "reward": 9},
+			{"title": "Admin", "reward": 2},
+			{"title": "Study", "estimatedHours": 1, "reward": 1,
+				"startOnOrAfter": "2015-02-20T15:00:00Z", "deadline": "2015-02-18T15:00:00Z"}
+		],
+		"startTaskSchedule": "2015-02-28T22:00:00Z",
+		"endTaskSchedule": "2015-02-16T14:00:00Z"
+	}`
+
+	Convey("Every problem with a request is reported with the field it's in", t, func() {
+		var tp TaskParams
+		err := parseTaskParams([]byte(in), &tp)
+		So(err, ShouldHaveSameTypeAs, &ValidationError{})
+		So(err.(*ValidationError).Errors, ShouldResemble, []FieldError{
+			{"timeZone", "is not a known time zone: America/Nowhere"},
+			{"endTaskSchedule", "must be after startTaskSchedule"},
+			{"weeklyTaskBlocks", "must have 7 days, from Sunday to Saturday"},
+			{"appointments[0].end", "must be after start"},
+			{"tasks[1].estimatedHours", "must be positive"},
+			{"tasks[2].deadline", "must be after startOnOrAfter"},
+		})
+	})
+
+	Convey("Blocks must end after they start", t, func() {
+		var tp TaskParams
+		err := parseTaskParams([]byte(strings.Replace(in, `[]
+		],`, `[], [], [], [], []
+		],`, 1)), &tp)
+		So(err, ShouldNotBeNil)
+		So(err.Error(), ShouldContainSubstring, "weeklyTaskBlocks[1][1].end must be after start")
+	})
+
+	Convey("JSON errors are reported with the field where known", t, func() {
+		var tp TaskParams
+		err := parseTaskParams([]byte(`{"tasks": [{"title": "A", "estimatedHours": "3"}]}`), &tp)
+		So(err.(*ValidationError).Errors, ShouldResemble, []FieldError{{"tasks[0].estimatedHours", "can't be a string"}})
+
+		err = parseTaskParams([]byte(`{"weeklyTaskBlocks": [[], [{"start": 9, "end": "12:00"}]]}`), &tp)
+		So(err.(*ValidationError).Errors, ShouldResemble, []FieldError{{"weeklyTaskBlocks[1][0].start", "can't be a number"}})
+
+		err = parseTaskParams([]byte(`{"weeklyTaskBlocks": [[], [{"start": "9am", "end": "12:00"}]]}`), &tp)
+		So(err.(*ValidationError).Errors, ShouldResemble, []FieldError{
+			{"weeklyTaskBlocks[1][0].start", `must be a time of day formatted as "15:04"`}})
+
+		err = parseTaskParams([]byte(`{"tasks": [{"title": "A", "deadline": "2015-02-16"}]}`), &tp)
+		So(err.(*ValidationError).Errors, ShouldResemble, []FieldError{
+			{"tasks[0].deadline", `must be a time formatted as RFC 3339, e.g. "2015-02-16T14:00:00Z"`}})
+
+		err = parseTaskParams([]byte(`{"tasks": [`), &tp)
+		So(err, ShouldHaveSameTypeAs, &ValidationError{})
+		So(err.Error(), ShouldStartWith, "invalid JSON")
+	})
+
+	Convey("An invalid request is a 400 Bad Request with the list of errors", t, func() {
+		r := httptest.NewRequest("POST", "/", strings.NewReader(in))
+		w := httptest.NewRecorder()
+		computeScheduleHandler(w, r)
+		So(w.Code, ShouldEqual, http.StatusBadRequest)
+
+		var resp struct{ Errors []FieldError }
+		So(json.Unmarshal(w.Body.Bytes(), &resp), ShouldBeNil)
+		So(len(resp.Errors), ShouldEqual, 6)
+		So(resp.Errors[0], ShouldResemble, FieldError{"timeZone", "is not a known time zone: America/Nowhere"})
+	})
+
+	Convey("Problems only found once the request is resolved are a 400 Bad Request too", t, func() {
+		valid := `{
+			"timeZone": "America/New_York",
+			"weeklyTaskBlocks": [[], [{"start": "9:00", "end": "12:00"}], [], [], [], [], []],
+			"startTaskSchedule": "2015-03-02T14:00:00Z",
+			"endTaskSchedule": "2015-03-03T14:00:00Z",
+			"tasks": [
+				{"title": "Draft", "estimatedHours": 1, "reward": 1},
+				{"title": "Send", "estimatedHours": 2, "reward": 1, "dependsOn": ["Draft"]}
+			]
+		}`
+		invalid := []struct{ old, new string }{
+			{`"tasks"`, `"objective": {"decayRate": 1.5}, "tasks"`},
+			{`"dependsOn": ["Draft"]`, `"dependsOn": ["Drafts"]`},
+			{`"dependsOn": ["Draft"]`, `"deadlineType": "firm"`},
+			{`"dependsOn": ["Draft"]`, `"minChunkHours": 2, "maxChunkHours": 1`},
+			{`"dependsOn": ["Draft"]`, `"recurrence": {"freq": "yearly"}`},
+			{`"tasks"`, `"appointmentsIcs": "BEGIN:VEVENT\r\nEND:VEVENT\r\n", "tasks"`},
+		}
+		expected := []FieldError{
+			{"objective.decayRate", "must be greater than 0 and at most 1"},
+			{"tasks[1].dependsOn[0]", "is not the id or title of a task: Drafts"},
+			{"tasks[1].deadlineType", `must be "hard" or "soft"`},
+			{"tasks[1].maxChunkHours", "must not be less than minChunkHours"},
+			{"tasks[1].recurrence", "is invalid: freq must be daily, weekly or monthly"},
+			{"appointmentsIcs", "is invalid: VEVENT without DTSTART: "},
+		}
+		for i, params := range invalid {
+			body := strings.Replace(valid, params.old, params.new, 1)
+			r := httptest.NewRequest("POST", "/", strings.NewReader(body))
+			w := httptest.NewRecorder()
+			computeScheduleHandler(w, r)
+			So(w.Code, ShouldEqual, http.StatusBadRequest)
+
+			var resp struct{ Errors []FieldError }
+			So(json.Unmarshal(w.Body.Bytes(), &resp), ShouldBeNil)
+			So(resp.Errors, ShouldResemble, []FieldError{expected[i]})
+		}
+	})
+}